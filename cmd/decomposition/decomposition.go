@@ -0,0 +1,188 @@
+package decomposition
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/Penpen7/goplot/cmd/simulationconfig"
+)
+
+// 領域分割の配置。Ranksは各軸(x, y, z)のプロセス数です。
+// ランク番号は ix + Ranks[0]*(iy + Ranks[1]*iz) で、x方向が最も速く変わります。
+type Layout struct {
+	Ranks [3]int
+}
+
+var axisName = [3]string{"x", "y", "z"}
+
+// 読み込み時に使う領域分割の配置
+var current = Layout{Ranks: [3]int{1, 1, 1}}
+
+// plot.jsonのDecompositionから領域分割の配置を決めます。
+// autoまたは空のときはy方向にParallelNumber個に分割されているとします。
+// "x=2,y=4"のように各軸のプロセス数を指定でき、指定のない軸は1です。積はParallelNumberと一致する必要があります。
+func New(config simulationconfig.SimulationConfig, spec string) (Layout, error) {
+	layout := Layout{Ranks: [3]int{1, 1, 1}}
+	if spec == "" || spec == "auto" {
+		layout.Ranks[1] = int(config.ParallelNumber)
+		if layout.Ranks[1] < 1 {
+			layout.Ranks[1] = 1
+		}
+		return layout, nil
+	}
+	for _, v := range strings.Split(spec, ",") {
+		name, value, found := strings.Cut(v, "=")
+		axis := -1
+		for i, a := range axisName {
+			if a == name {
+				axis = i
+			}
+		}
+		n, err := strconv.Atoi(value)
+		if !found || axis < 0 || err != nil || n < 1 {
+			return layout, fmt.Errorf("invalid decomposition: %s", v)
+		}
+		layout.Ranks[axis] = n
+	}
+	if total := layout.Ranks[0] * layout.Ranks[1] * layout.Ranks[2]; total != int(config.ParallelNumber) {
+		return layout, fmt.Errorf("プロセス数の積(%d)がParallelNumber(%d)と一致しません: %s", total, config.ParallelNumber, spec)
+	}
+	return layout, nil
+}
+
+// 読み込み時に使う領域分割の配置を設定します。指定が正しくなければy方向の分割にします。
+func Configure(config simulationconfig.SimulationConfig, spec string) error {
+	layout, err := New(config, spec)
+	if err != nil {
+		current, _ = New(config, "auto")
+		return err
+	}
+	current = layout
+	return nil
+}
+
+// n個の格子をranks個に分けたときの各ランクの開始位置と個数を返します。
+// 割り切れない場合は、先頭のn%ranks個のランクが1つずつ多く持つとしています。
+// シミュレーション側の分け方はこのリポジトリにないため確かめていません。割り切れない分割ではReorderが一度警告します。
+func Split(n int, ranks int) (offsets []int, counts []int) {
+	offset := 0
+	for r := 0; r < ranks; r++ {
+		count := n / ranks
+		if r < n%ranks {
+			count++
+		}
+		offsets = append(offsets, offset)
+		counts = append(counts, count)
+		offset += count
+	}
+	return offsets, counts
+}
+
+// 割り切れない分割の警告を一度だけ出すため
+var unevenWarning sync.Once
+
+// ランクごとのブロックを連結した1次元配列を、全体の配列の順に並べ替えます。
+// dimsは全体の各次元の大きさで、最初の次元が最も速く変わる順です。各ブロックも同じ順に並んでいるとします。
+// axes[k]は次元kに対応する空間軸(0, 1, 2)で、運動量などの分割されない次元は-1です。
+// 配列に含まれない軸の方向のランクについては、足し合わされて1つのブロックになっているとします。
+// 配列の大きさがdimsの積と一致しなければエラーを返します。
+func (layout Layout) Reorder(data []float32, dims []int, axes []int) ([]float32, error) {
+	size := 1
+	for _, n := range dims {
+		size *= n
+	}
+	if size != len(data) {
+		return data, fmt.Errorf("配列の大きさ(%d)がメッシュ数の積(%d)と一致しません", len(data), size)
+	}
+	ranks := make([]int, len(dims))
+	for k, axis := range axes {
+		ranks[k] = 1
+		if axis >= 0 {
+			ranks[k] = layout.Ranks[axis]
+		}
+		if dims[k]%ranks[k] != 0 {
+			unevenWarning.Do(func() {
+				fmt.Printf("\x1b[35mwarning : %s方向の%d格子が%dプロセスで割り切れません。先頭のプロセスが1つずつ多く持つとして並べ替えます。\x1b[0m\n", axisName[axis], dims[k], ranks[k])
+			})
+		}
+	}
+	// 分割されているのが最も遅く変わる次元だけなら、連結した順がそのまま全体の順になる
+	identity := true
+	for k := 0; k < len(dims)-1; k++ {
+		if ranks[k] > 1 {
+			identity = false
+		}
+	}
+	if identity || size == 0 {
+		return data, nil
+	}
+
+	offsets := make([][]int, len(dims))
+	counts := make([][]int, len(dims))
+	strides := make([]int, len(dims))
+	stride := 1
+	for k := range dims {
+		offsets[k], counts[k] = Split(dims[k], ranks[k])
+		strides[k] = stride
+		stride *= dims[k]
+	}
+	// ブロックの中は次元の順に、ランクの順は空間軸x, y, zの順に速く変わる
+	localOrder := make([]int, len(dims))
+	for k := range localOrder {
+		localOrder[k] = k
+	}
+	rankOrder := []int{}
+	for axis := 0; axis < 3; axis++ {
+		for k := range axes {
+			if axes[k] == axis {
+				rankOrder = append(rankOrder, k)
+			}
+		}
+	}
+	res := make([]float32, len(data))
+	index := 0
+	rank := make([]int, len(dims))
+	local := make([]int, len(dims))
+	count := make([]int, len(dims))
+	for {
+		empty := false
+		for k := range count {
+			count[k] = counts[k][rank[k]]
+			empty = empty || count[k] == 0
+		}
+		for !empty {
+			global := 0
+			for k := range dims {
+				global += (offsets[k][rank[k]] + local[k]) * strides[k]
+			}
+			res[global] = data[index]
+			index++
+			if !next(local, count, localOrder) {
+				break
+			}
+		}
+		if !next(rank, ranks, rankOrder) {
+			break
+		}
+	}
+	return res, nil
+}
+
+// 多重の添字を1つ進めます。添字はorderの順に速く変わり、index[k]はsize[k]未満です。全て回り終えたらfalseを返します。
+func next(index []int, size []int, order []int) bool {
+	for _, k := range order {
+		index[k]++
+		if index[k] < size[k] {
+			return true
+		}
+		index[k] = 0
+	}
+	return false
+}
+
+// 設定された領域分割の配置でReorderします。
+func Reorder(data []float32, dims []int, axes []int) ([]float32, error) {
+	return current.Reorder(data, dims, axes)
+}
@@ -0,0 +1,91 @@
+package decomposition
+
+import (
+	"reflect"
+	"testing"
+)
+
+// y方向にparallelNumber個に分割された位置と運動量の分布を並べ替える、以前のutility.Transpyです。
+func transpy(yp []float32, Ny_d int, parallelNumber int, momentumMeshNumber int) [][]float32 {
+	var res [][]float32 = make([][]float32, Ny_d)
+	for y := 0; y < Ny_d; y++ {
+		res[y] = make([]float32, len(yp)/int(Ny_d))
+	}
+
+	Ny_d_pe := Ny_d / parallelNumber
+
+	for ypindex, v := range yp {
+		mype := ypindex / (Ny_d_pe * momentumMeshNumber)
+		ipe := ypindex - mype*Ny_d_pe*momentumMeshNumber
+		kp := (ipe) / Ny_d_pe
+		ky_pe := (ipe) % (Ny_d_pe)
+		kynew := Ny_d_pe*mype + ky_pe
+		res[kynew][kp] = v
+	}
+	return res
+}
+
+func sequence(n int) []float32 {
+	data := make([]float32, n)
+	for i := range data {
+		data[i] = float32(i)
+	}
+	return data
+}
+
+func TestReorderMatchesTranspy(t *testing.T) {
+	const ny, ranks, momentum = 8, 4, 3
+	data := sequence(ny * momentum)
+	want := transpy(data, ny, ranks, momentum)
+	got, err := Layout{Ranks: [3]int{1, ranks, 1}}.Reorder(data, []int{ny, momentum}, []int{1, -1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for y := 0; y < ny; y++ {
+		for p := 0; p < momentum; p++ {
+			if got[y+ny*p] != want[y][p] {
+				t.Errorf("y=%d p=%d: got %g, want %g", y, p, got[y+ny*p], want[y][p])
+			}
+		}
+	}
+}
+
+func TestReorder(t *testing.T) {
+	tests := []struct {
+		name   string
+		layout Layout
+		dims   []int
+		axes   []int
+		want   []float32
+	}{
+		// 5格子を2プロセスで分けると3格子と2格子になる
+		// rank0: (0,0) (1,0) (2,0) (0,1) (1,1) (2,1), rank1: (3,0) (4,0) (3,1) (4,1)
+		{"uneven", Layout{Ranks: [3]int{2, 1, 1}}, []int{5, 2}, []int{0, -1},
+			[]float32{0, 1, 2, 6, 7, 3, 4, 5, 8, 9}},
+		// 4x2の格子をx方向2, y方向2に分ける。ランクはxが速く変わる
+		// rank0: (0,0) (1,0), rank1: (2,0) (3,0), rank2: (0,1) (1,1), rank3: (2,1) (3,1)
+		{"2D", Layout{Ranks: [3]int{2, 2, 1}}, []int{4, 2}, []int{0, 1},
+			[]float32{0, 1, 2, 3, 4, 5, 6, 7}},
+		// x方向2, y方向2で、yが遅い次元でなく先に来る並び(y, x)
+		// rank0(x0,y0): (0,0) (1,0), rank1(x1,y0): (0,1) (1,1), rank2(x0,y1): (2,0) (3,0), rank3(x1,y1): (2,1) (3,1)
+		{"2D transposed", Layout{Ranks: [3]int{2, 2, 1}}, []int{4, 2}, []int{1, 0},
+			[]float32{0, 1, 4, 5, 2, 3, 6, 7}},
+	}
+	for _, tt := range tests {
+		got, err := tt.layout.Reorder(sequence(len(tt.want)), tt.dims, tt.axes)
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: got %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestReorderSizeMismatch(t *testing.T) {
+	layout := Layout{Ranks: [3]int{1, 2, 1}}
+	if _, err := layout.Reorder(sequence(5), []int{4, 2}, []int{1, -1}); err == nil {
+		t.Error("expected an error for a size mismatch")
+	}
+}
@@ -0,0 +1,114 @@
+package energydistribution
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"sync"
+
+	"github.com/Penpen7/goplot/cmd/fieldop"
+	"github.com/Penpen7/goplot/cmd/plotconfig"
+	"github.com/Penpen7/goplot/cmd/plotscript"
+	"github.com/Penpen7/goplot/cmd/runtable"
+	"github.com/Penpen7/goplot/cmd/simulationconfig"
+)
+
+// 最初のステップの粒子種ごとの運動エネルギー。変換効率の基準にします。
+var initialKinetic map[int32]float64
+
+// カットオフエネルギー(eV)。E以上の粒子数がthreshold以上になる最大のEで、
+// 孤立したビンの少数の粒子に左右されないよう、ビンごとではなく積算した粒子数で判定します。
+func cutoffEnergy(s spectrum, threshold float64) float64 {
+	above := 0.0
+	for i := len(s.population) - 1; i >= 0; i-- {
+		above += float64(s.population[i])
+		if above >= threshold {
+			return s.high[i]
+		}
+	}
+	return 0
+}
+
+// energy(eV)以上の粒子数。energyをまたぐビンは幅に比例して数えます。
+func countAbove(s spectrum, energy float64) float64 {
+	count := 0.0
+	for i, v := range s.population {
+		switch {
+		case s.low[i] >= energy:
+			count += float64(v)
+		case s.high[i] > energy:
+			count += float64(v) * (s.high[i] - energy) / (s.high[i] - s.low[i])
+		}
+	}
+	return count
+}
+
+// レーザーのエネルギーが求められない警告を一度だけ出すため
+var laserEnergyWarning sync.Once
+
+// レーザーのエネルギー(n0 Δx^3 ε0、運動エネルギーと同じ単位)。plot.jsonのLaserEnergyが正ならその値を使います。
+// そうでなければgfin.datのレーザーの設定から、振幅E0の直線偏光の平面波の周期平均のエネルギー密度E0^2/2
+// (規格化単位では|B|=|E|なので (E^2+B^2)/2 の周期平均)に、パルスの長さc·Tau0とスポットの面積をかけて見積もります。
+// スポットの面積はy方向の幅Dy0(0以下なら系の幅)とz方向の系の幅の積で、系の幅は運動エネルギーを積分する
+// 出力メッシュの範囲です。包絡線の形による係数はかけず、振幅E0の平坦なパルスとみなしています。
+func laserEnergy(config simulationconfig.SimulationConfig, plotConfig plotconfig.Art) float64 {
+	if plotConfig.LaserEnergy > 0 {
+		return plotConfig.LaserEnergy
+	}
+	grid := fieldop.NewGrid(config, plotConfig.FieldBoundary, plotConfig.FieldStaggering)
+	width := config.Laser.Dy0
+	if width <= 0 {
+		width = grid.Spacing[1] * float64(config.OutputMeshNumber[1])
+	}
+	depth := grid.Spacing[2] * float64(config.OutputMeshNumber[2])
+	return 0.5 * config.Laser.E0 * config.Laser.E0 * config.VelocityLight * config.Laser.Tau0 * width * depth
+}
+
+// 粒子種ごとのカットオフエネルギー、AboveEnergiesに指定したエネルギー以上の粒子数、
+// レーザーのエネルギーに対する変換効率をAcceleration.txtに1行記録します。
+// spectraは粒子種ごとのエネルギー分布、kineticは粒子種ごとの運動エネルギー(規格化単位)で、
+// 変換効率は最初のステップからの運動エネルギーの増加をlaserEnergyで割った値で、
+// レーザーのエネルギーが0以下ならNaNです。
+func recordAcceleration(spectra map[int32]spectrum, kinetic map[int32]float64, config simulationconfig.SimulationConfig, plotConfig plotconfig.Art, fileID int, simulationTime float32) {
+	if subart, found := plotconfig.FindSubart(plotConfig.EnergyDistribution, "Acceleration"); !found || !subart.Plot {
+		return
+	}
+	labels, thresholds := []string{}, []float64{}
+	for _, v := range strings.Fields(plotConfig.AboveEnergies) {
+		energy, err := parseEnergy(v)
+		if err != nil {
+			fmt.Println("Warning:AboveEnergies", err)
+			continue
+		}
+		labels, thresholds = append(labels, v), append(thresholds, energy)
+	}
+	if initialKinetic == nil {
+		initialKinetic = kinetic
+	}
+	laser := laserEnergy(config, plotConfig)
+	if laser <= 0 {
+		laserEnergyWarning.Do(func() {
+			fmt.Printf("\n\x1b[35mwarning : レーザーのエネルギーが0以下のため、変換効率はNaNになります。plot.jsonのLaserEnergyで指定してください。\x1b[0m\n")
+		})
+	}
+
+	columns := []plotscript.Column{{Name: "time", Unit: "normalized"}, {Name: "step"}}
+	row := []float64{float64(simulationTime), float64(fileID)}
+	for species := int32(1); species <= config.TotalParticleSpecies; species++ {
+		name := plotconfig.SpeciesName(plotConfig, config, species)
+		s := spectra[species]
+		columns = append(columns, plotscript.Column{Name: fmt.Sprintf("Cutoff_%s_is=%02d", name, species), Unit: "MeV"})
+		row = append(row, cutoffEnergy(s, plotConfig.CutoffCount)/1e+6)
+		for k, energy := range thresholds {
+			columns = append(columns, plotscript.Column{Name: fmt.Sprintf("Above%s_%s_is=%02d", labels[k], name, species)})
+			row = append(row, countAbove(s, energy))
+		}
+		efficiency := math.NaN()
+		if laser > 0 {
+			efficiency = (kinetic[species] - initialKinetic[species]) / laser
+		}
+		columns = append(columns, plotscript.Column{Name: fmt.Sprintf("Efficiency_%s_is=%02d", name, species)})
+		row = append(row, efficiency)
+	}
+	runtable.Append(plotConfig.OutputASCIIDirectory, "Acceleration", fileID, columns, row)
+}
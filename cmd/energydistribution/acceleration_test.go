@@ -0,0 +1,34 @@
+package energydistribution
+
+import (
+	"math"
+	"testing"
+
+	"github.com/Penpen7/goplot/cmd/plotconfig"
+	"github.com/Penpen7/goplot/cmd/simulationconfig"
+)
+
+func TestLaserEnergy(t *testing.T) {
+	config := simulationconfig.SimulationConfig{VelocityLight: 2, SystemL: [3]float64{40, 20, 6}, OutputMeshNumber: [3]int32{40, 10, 3}}
+	config.Laser.E0 = 3
+	config.Laser.Tau0 = 5
+	tests := []struct {
+		name   string
+		dy0    float64
+		energy float64
+		want   float64
+	}{
+		// 0.5 * E0^2 * c * Tau0 * Dy0 * Lz = 0.5 * 9 * 2 * 5 * 4 * 6
+		{"spot", 4, 0, 1080},
+		// Dy0が0ならy方向の系の幅Ly=20を使う
+		{"plane wave", 0, 0, 5400},
+		{"override", 4, 123, 123},
+	}
+	for _, tt := range tests {
+		config.Laser.Dy0 = tt.dy0
+		got := laserEnergy(config, plotconfig.Art{LaserEnergy: tt.energy})
+		if math.Abs(got-tt.want) > 1e-9*tt.want {
+			t.Errorf("%s: laserEnergy = %g, want %g", tt.name, got, tt.want)
+		}
+	}
+}
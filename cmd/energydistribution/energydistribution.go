@@ -11,43 +11,143 @@ import (
 	"github.com/Penpen7/goplot/cmd/fortbin"
 	"github.com/Penpen7/goplot/cmd/physconst"
 	"github.com/Penpen7/goplot/cmd/plotconfig"
+	"github.com/Penpen7/goplot/cmd/plotscript"
 	"github.com/Penpen7/goplot/cmd/simulationconfig"
+	"github.com/Penpen7/goplot/cmd/svgplot"
 )
 
-func writeEnergyDistribution(dltEnergy float32, population []float32, fileName string, wg *sync.WaitGroup) {
-	fout, err := os.Create(fileName)
-	defer fout.Close()
-	if err != nil {
-		panic(err)
+// 対数ビンのエネルギー分布が覆う桁数。最大エネルギーEimaxtから下に10桁です。
+const logDecades = 10
+
+// ビンごとのエネルギー分布。low, highはビンの下端と上端(eV)です。
+type spectrum struct {
+	low        []float64
+	high       []float64
+	population []float32
+}
+
+// 幅dltEnergy(規格化単位)の線形ビンのエネルギー分布を作ります。
+func linearSpectrum(dltEnergy float32, population []float32) spectrum {
+	s := spectrum{low: make([]float64, len(population)), high: make([]float64, len(population)), population: population}
+	width := float64(dltEnergy) * float64(physconst.NormalizedEnergy)
+	for i := range population {
+		s.low[i] = float64(i) * width
+		s.high[i] = float64(i+1) * width
 	}
-	writer := bufio.NewWriter(fout)
-	writer.WriteString("# energy(eV) population\n")
-	for i, v := range population {
-		energy := (float32(i+1) - 0.5) * dltEnergy * physconst.NormalizedEnergy
-		writer.WriteString(fmt.Sprintln(energy, v))
+	return s
+}
+
+// 最大エネルギーEimaxt(規格化単位)から下にlogDecades桁を等分した対数ビンのエネルギー分布を作ります。
+func logSpectrum(Eimaxt float32, population []float32) spectrum {
+	s := spectrum{low: make([]float64, len(population)), high: make([]float64, len(population)), population: population}
+	maxEnergy := float64(Eimaxt) * float64(physconst.NormalizedEnergy)
+	deltaLog := float64(logDecades) / float64(len(population))
+	for i := range population {
+		s.low[i] = maxEnergy * math.Pow(10, float64(i)*deltaLog-logDecades)
+		s.high[i] = maxEnergy * math.Pow(10, float64(i+1)*deltaLog-logDecades)
 	}
-	writer.Flush()
-	wg.Done()
+	return s
+}
+
+// ビンの代表エネルギー(eV)。線形ビンでは中点、対数ビンでは幾何平均です。
+func (s spectrum) centre(i int, log bool) float64 {
+	if log {
+		return math.Sqrt(s.low[i] * s.high[i])
+	}
+	return (s.low[i] + s.high[i]) / 2
+}
+
+// ビン幅で割ったdN/dE(1/eV)。幅が0のビンは0です。
+func (s spectrum) density(i int) float64 {
+	width := s.high[i] - s.low[i]
+	if width <= 0 {
+		return 0
+	}
+	return float64(s.population[i]) / width
+}
+
+// エネルギー分布の系列(eV)を作ります。
+func energySeries(label string, s spectrum, log bool) svgplot.Series {
+	series := svgplot.Series{Label: label, X: make([]float64, len(s.population)), Y: make([]float64, len(s.population))}
+	for i, v := range s.population {
+		series.X[i] = s.centre(i, log)
+		series.Y[i] = float64(v)
+	}
+	return series
 }
-func writeLogLogEnergyDistribution(dltEnergy float32, population []float32, fileName string, wg *sync.WaitGroup) {
+
+// エネルギー分布を書き出します。線形ビンと対数ビンで同じ列を持ち、
+// dN/dEはビン幅で割ってあるので両者を比べられます。
+func writeEnergyDistribution(s spectrum, log bool, fileName string, wg *sync.WaitGroup) {
 	fout, err := os.Create(fileName)
 	defer fout.Close()
 	if err != nil {
 		panic(err)
 	}
 	writer := bufio.NewWriter(fout)
-	deltaNumber := float32(10) / float32(len(population))
-	writer.WriteString("# energy(eV) population\n")
-	for i, v := range population {
-		TlogE := float64(i)*float64(deltaNumber) - float64(10)
-		energy := float64(float64(i+1)-0.5) * float64(dltEnergy*physconst.NormalizedEnergy) * math.Pow(10.0, TlogE)
-
-		writer.WriteString(fmt.Sprintln(energy, v))
+	writer.WriteString("# energy(eV) population energy_low(eV) energy_high(eV) dN/dE(1/eV)\n")
+	for i, v := range s.population {
+		writer.WriteString(fmt.Sprintln(s.centre(i, log), v, s.low[i], s.high[i], s.density(i)))
 	}
 	writer.Flush()
 	wg.Done()
 }
-func LoadWriteEnergyDistribution(file *os.File, config simulationconfig.SimulationConfig, plotConfig plotconfig.Art, fileID int, wg *sync.WaitGroup) {
+
+// 粒子種speciesのエネルギー分布quantityの解析の指定を探します。
+// 粒子種の名前をつけた名前(C_Energy_Distributionなど)、Ion_またはElectron_をつけた名前、quantityの順に探します。
+// 戻り値の名前は出力ファイルに使う粒子種の名前をつけた名前です。
+func findSpectrumSubart(subarts []plotconfig.Subart, quantity string, config simulationconfig.SimulationConfig, plotConfig plotconfig.Art, species int32) (plotconfig.Subart, string, bool) {
+	name := plotconfig.SpeciesName(plotConfig, config, species) + "_" + quantity
+	for _, v := range []string{name, plotconfig.SpeciesKind(config, species) + "_" + quantity, quantity} {
+		if subart, found := plotconfig.FindSubart(subarts, v); found {
+			return subart, name, true
+		}
+	}
+	return plotconfig.Subart{}, name, false
+}
+
+// エネルギー分布のテキスト出力をスクリプト生成用に登録します。
+func registerScript(name string, fileID int, species int32, logx bool) {
+	plotscript.Register(plotscript.Kind{
+		Name:    fmt.Sprintf("%s_is=%02d", name, species),
+		Pattern: fmt.Sprintf("%s%%04d_is=%02d.txt", name, species),
+		Format:  plotscript.Line,
+		Columns: []plotscript.Column{{Name: "energy", Unit: "eV"}, {Name: "population"}, {Name: "energy_low", Unit: "eV"}, {Name: "energy_high", Unit: "eV"}, {Name: "dN/dE", Unit: "1/eV"}},
+		LogX:    logx,
+		LogY:    true,
+	}, fileID)
+}
+func LoadWriteEnergyDistribution(file *os.File, config simulationconfig.SimulationConfig, plotConfig plotconfig.Art, fileID int, simulationTime float32, kinetic map[int32]float64, wg *sync.WaitGroup) {
+	// 全粒子種を1枚に重ねたSVGのグラフ
+	overlay := map[string]*svgplot.Chart{}
+	addOverlay := func(subart plotconfig.Subart, outputName string, series svgplot.Series) {
+		if !plotconfig.HasFlag(subart.Center, "svg") {
+			return
+		}
+		if _, exists := overlay[outputName]; !exists {
+			overlay[outputName] = &svgplot.Chart{
+				Title: fmt.Sprintf("%s %04d", outputName, fileID),
+				XAxis: svgplot.Axis{Label: "energy", Unit: "eV", Log: plotconfig.HasFlag(subart.Center, "logx")},
+				YAxis: svgplot.Axis{Label: "population", Log: plotconfig.HasFlag(subart.Center, "logy")},
+			}
+		}
+		overlay[outputName].Series = append(overlay[outputName].Series, series)
+	}
+	// 粒子種speciesのエネルギー分布を、ParticleまたはSpeciesのconfigNameの指定に従って書き出し、解析する
+	writeSpectrum := func(s spectrum, log bool, species int32, configName string, quantity string) {
+		name := plotconfig.SpeciesName(plotConfig, config, species)
+		subart, found := plotconfig.FindSubart(plotconfig.ParticleSubarts(plotConfig, config, species), configName)
+		if found && subart.Plot {
+			wg.Add(1)
+			go writeEnergyDistribution(s, log, fmt.Sprintf("%s/%s_%s%04d_is=%02d.txt", plotConfig.OutputASCIIDirectory, name, quantity, fileID, species), wg)
+			registerScript(name+"_"+quantity, fileID, species, log)
+			addOverlay(subart, quantity, energySeries(fmt.Sprintf("%s is=%02d", name, species), s, log))
+		}
+		fitSpectrum(s, log, quantity, config, plotConfig, fileID, species, simulationTime, wg)
+		rebinSpectrum(s, log, quantity, config, plotConfig, fileID, species, simulationTime, wg)
+	}
+	// カットオフエネルギーには最大エネルギーまで覆う対数ビンの分布を使う
+	spectra := map[int32]spectrum{}
 	for i := int32(1); i <= config.TotalParticleSpecies; i++ {
 		var averageChargeRate, averageEnergy, dltEnergy, Eimaxt float32
 		binary.Read(fortbin.ReadNextChunk(file), binary.LittleEndian, &averageChargeRate)
@@ -55,49 +155,25 @@ func LoadWriteEnergyDistribution(file *os.File, config simulationconfig.Simulati
 		binary.Read(fortbin.ReadNextChunk(file), binary.LittleEndian, &dltEnergy)
 		population := make([]float32, config.MomentumMeshNumber)
 		binary.Read(fortbin.ReadNextChunk(file), binary.LittleEndian, &population)
-		if i <= config.IonNumber {
-			if isfound := plotconfig.SearchSubart(plotConfig.Particle, "Ion_Energy_Distribution"); isfound {
-				wg.Add(1)
-				go writeEnergyDistribution(dltEnergy, population, fmt.Sprintf("%s/Ion_Energy_Distribution%04d_is=%02d.txt", plotConfig.OutputASCIIDirectory, fileID, i), wg)
-			}
-		} else {
-			if isfound := plotconfig.SearchSubart(plotConfig.Particle, "Electron_Energy_Distribution"); isfound {
-				wg.Add(1)
-				go writeEnergyDistribution(dltEnergy, population, fmt.Sprintf("%s/Electron_Energy_Distribution%04d_is=%02d.txt", plotConfig.OutputASCIIDirectory, fileID, i), wg)
-			}
-		}
+		writeSpectrum(linearSpectrum(dltEnergy, population), false, i, "Energy_Distribution", "Energy_Distribution")
 		fortbin.ReadNextChunk(file) //FF2
 		fortbin.ReadNextChunk(file) //FF3
 
 		// log-log
 		binary.Read(fortbin.ReadNextChunk(file), binary.LittleEndian, &Eimaxt)
+		population = make([]float32, config.MomentumMeshNumber)
 		binary.Read(fortbin.ReadNextChunk(file), binary.LittleEndian, &population)
 		fortbin.ReadNextChunk(file) //FF2
 		fortbin.ReadNextChunk(file) //FF3
-		if i <= config.IonNumber {
-			if isfound := plotconfig.SearchSubart(plotConfig.Particle, "Ion_Energy_DistributionLogLog"); isfound {
-				wg.Add(1)
-				go writeEnergyDistribution(dltEnergy, population, fmt.Sprintf("%s/Ion_Energy_DistributionLog%04d_is=%02d.txt", plotConfig.OutputASCIIDirectory, fileID, i), wg)
-			}
-		} else {
-			if isfound := plotconfig.SearchSubart(plotConfig.Particle, "Electron_Energy_DistributionLogLog"); isfound {
-				wg.Add(1)
-				go writeEnergyDistribution(dltEnergy, population, fmt.Sprintf("%s/Electron_Energy_DistributionLog%04d_is=%02d.txt", plotConfig.OutputASCIIDirectory, fileID, i), wg)
-			}
-		}
+		spectra[i] = logSpectrum(Eimaxt, population)
+		writeSpectrum(spectra[i], true, i, "Energy_DistributionLogLog", "Energy_DistributionLog")
+	}
+	for name, chart := range overlay {
+		wg.Add(1)
+		go svgplot.WriteLinePlot(*chart, fmt.Sprintf("%s/%s%04d.svg", plotConfig.OutputSVGDirectory, name, fileID), wg)
 	}
 	for i := int32(1); i <= config.TotalParticleSpecies; i++ {
-		fortbin.ReadNextChunk(file)
-		fortbin.ReadNextChunk(file)
-		fortbin.ReadNextChunk(file)
-		fortbin.ReadNextChunk(file)
-		fortbin.ReadNextChunk(file)
-		fortbin.ReadNextChunk(file)
-		fortbin.ReadNextChunk(file)
-		fortbin.ReadNextChunk(file)
-		fortbin.ReadNextChunk(file)
-		fortbin.ReadNextChunk(file)
-		fortbin.ReadNextChunk(file)
-		fortbin.ReadNextChunk(file)
+		loadWriteRecords(file, config, plotConfig, fileID, i, wg)
 	}
+	recordAcceleration(spectra, kinetic, config, plotConfig, fileID, simulationTime)
 }
@@ -0,0 +1,400 @@
+package energydistribution
+
+import (
+	"bufio"
+	"fmt"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/Penpen7/goplot/cmd/physconst"
+	"github.com/Penpen7/goplot/cmd/plotconfig"
+	"github.com/Penpen7/goplot/cmd/plotscript"
+	"github.com/Penpen7/goplot/cmd/runtable"
+	"github.com/Penpen7/goplot/cmd/simulationconfig"
+)
+
+// フィットに使うビン。energyはビンの代表エネルギー(eV)、densityはdN/dE、weightは粒子数です。
+type fitPoint struct {
+	energy  float64
+	density float64
+	weight  float64
+}
+
+// 1つのモデルのフィット結果。温度と誤差はeVです。
+// curveはフィットした曲線のdN/dE(1/eV)です。
+type fitResult struct {
+	temperatures []float64
+	errors       []float64
+	curve        func(energy float64) float64
+}
+
+// モデルごとの温度の列の名前。
+var fitColumns = map[string][]string{
+	"exp":      {"T_exp"},
+	"maxwell":  {"T_maxwell"},
+	"juttner":  {"T_juttner"},
+	"maxwell2": {"T1_maxwell2", "T2_maxwell2"},
+}
+
+// plot.jsonのEnergyFitのCenterを解釈します。
+// exp, maxwell, juttner, maxwell2のモデルと、window=10keV:1MeVのようなフィットするエネルギーの範囲を指定します。
+func parseFitSpec(center string) (models []string, window [2]float64, err error) {
+	window = [2]float64{0, math.Inf(1)}
+	for _, v := range strings.Fields(center) {
+		if value, isWindow := strings.CutPrefix(v, "window="); isWindow {
+			if value == "all" {
+				continue
+			}
+			from, to, isRange := strings.Cut(value, ":")
+			if !isRange {
+				return nil, window, fmt.Errorf("invalid window: %s", v)
+			}
+			if from != "" {
+				if window[0], err = parseEnergy(from); err != nil {
+					return nil, window, err
+				}
+			}
+			if to != "" {
+				if window[1], err = parseEnergy(to); err != nil {
+					return nil, window, err
+				}
+			}
+			continue
+		}
+		if _, isModel := fitColumns[v]; !isModel {
+			return nil, window, fmt.Errorf("invalid fit model: %s", v)
+		}
+		models = append(models, v)
+	}
+	return models, window, nil
+}
+
+// 10keVのような単位つきのエネルギーをeVで返します。単位がなければeVです。
+func parseEnergy(s string) (float64, error) {
+	scale := 1.0
+	for _, unit := range []struct {
+		suffix string
+		scale  float64
+	}{{"GeV", 1e+9}, {"MeV", 1e+6}, {"keV", 1e+3}, {"eV", 1}} {
+		if number, found := strings.CutSuffix(s, unit.suffix); found {
+			s, scale = number, unit.scale
+			break
+		}
+	}
+	v, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid energy: %s", s)
+	}
+	return v * scale, nil
+}
+
+// plot.jsonのEnergyFitに粒子種speciesのエネルギー分布quantityの指定があれば、分布をフィットします。
+// 温度の時系列を記録し、フィットした曲線を分布と並べて書き出します。
+func fitSpectrum(s spectrum, log bool, quantity string, config simulationconfig.SimulationConfig, plotConfig plotconfig.Art, fileID int, species int32, simulationTime float32, wg *sync.WaitGroup) {
+	subart, name, found := findSpectrumSubart(plotConfig.EnergyFit, quantity, config, plotConfig, species)
+	if !found || !subart.Plot {
+		return
+	}
+	models, window, err := parseFitSpec(subart.Center)
+	if err != nil {
+		fmt.Println("Warning:", name, err)
+		return
+	}
+	points := []fitPoint{}
+	for i, v := range s.population {
+		energy := s.centre(i, log)
+		if v > 0 && energy > 0 && energy >= window[0] && energy <= window[1] {
+			points = append(points, fitPoint{energy, s.density(i), float64(v)})
+		}
+	}
+	restEnergy := config.Particle[species-1].ParticleMass * physconst.ElectronRestEnergy * 1e+3
+
+	columns := []plotscript.Column{{Name: "time", Unit: "normalized"}, {Name: "step"}}
+	row := []float64{float64(simulationTime), float64(fileID)}
+	results := make([]fitResult, len(models))
+	for m, model := range models {
+		results[m] = fitModel(model, points, restEnergy)
+		for k, column := range fitColumns[model] {
+			columns = append(columns, plotscript.Column{Name: column, Unit: "keV"}, plotscript.Column{Name: "d" + column, Unit: "keV"})
+			t, dt := math.NaN(), math.NaN()
+			if results[m].temperatures != nil {
+				t, dt = results[m].temperatures[k]/1e+3, results[m].errors[k]/1e+3
+			}
+			row = append(row, t, dt)
+		}
+	}
+	runtable.Append(plotConfig.OutputASCIIDirectory, fmt.Sprintf("EnergyFit_%s_is=%02d", name, species), fileID, columns, row)
+
+	fitName := name + "_Fit"
+	wg.Add(1)
+	go writeFitCurve(s, log, models, results, fmt.Sprintf("%s/%s%04d_is=%02d.txt", plotConfig.OutputASCIIDirectory, fitName, fileID, species), wg)
+	curveColumns := []plotscript.Column{{Name: "energy", Unit: "eV"}, {Name: "dN/dE", Unit: "1/eV"}}
+	for _, model := range models {
+		curveColumns = append(curveColumns, plotscript.Column{Name: model, Unit: "1/eV"})
+	}
+	plotscript.Register(plotscript.Kind{
+		Name:    fmt.Sprintf("%s_is=%02d", fitName, species),
+		Pattern: fmt.Sprintf("%s%%04d_is=%02d.txt", fitName, species),
+		Format:  plotscript.Line,
+		Columns: curveColumns,
+		LogX:    log,
+		LogY:    true,
+	}, fileID)
+}
+
+// モデルをフィットします。フィットできなければtemperaturesはnilです。
+func fitModel(model string, points []fitPoint, restEnergy float64) fitResult {
+	// f(E) = shape(E) exp(a - E/T)の形のモデルは、ln(f/shape)をEについて直線でフィットする
+	shapes := map[string]func(float64) float64{
+		"exp":     func(float64) float64 { return 1 },
+		"maxwell": math.Sqrt,
+		"juttner": func(energy float64) float64 {
+			gamma := 1 + energy/restEnergy
+			return gamma * math.Sqrt(gamma*gamma-1)
+		},
+	}
+	if shape, isLinear := shapes[model]; isLinear {
+		intercept, slope, slopeError, ok := fitExponent(points, shape)
+		if !ok {
+			return fitResult{}
+		}
+		return fitResult{
+			temperatures: []float64{-1 / slope},
+			errors:       []float64{slopeError / (slope * slope)},
+			curve: func(energy float64) float64 {
+				return shape(energy) * math.Exp(intercept+slope*energy)
+			},
+		}
+	}
+	return fitMaxwell2(points)
+}
+
+// ln(f/shape)をEについて重みつき最小二乗法で直線フィットします。
+// 重みは粒子数(ln fの分散の逆数)で、傾きの誤差は残差から見積もります。
+func fitExponent(points []fitPoint, shape func(float64) float64) (intercept float64, slope float64, slopeError float64, ok bool) {
+	if len(points) < 3 {
+		return 0, 0, 0, false
+	}
+	var s, sx, sxx, sy, sxy float64
+	for _, p := range points {
+		y := math.Log(p.density / shape(p.energy))
+		s += p.weight
+		sx += p.weight * p.energy
+		sxx += p.weight * p.energy * p.energy
+		sy += p.weight * y
+		sxy += p.weight * p.energy * y
+	}
+	delta := s*sxx - sx*sx
+	if delta <= 0 {
+		return 0, 0, 0, false
+	}
+	slope = (s*sxy - sx*sy) / delta
+	intercept = (sxx*sy - sx*sxy) / delta
+	if slope >= 0 {
+		return 0, 0, 0, false
+	}
+	var chi2 float64
+	for _, p := range points {
+		r := math.Log(p.density/shape(p.energy)) - intercept - slope*p.energy
+		chi2 += p.weight * r * r
+	}
+	slopeError = math.Sqrt(s / delta * chi2 / float64(len(points)-2))
+	return intercept, slope, slopeError, true
+}
+
+// 2温度のマクスウェル分布 f(E) = √E (exp(a1 - E/T1) + exp(a2 - E/T2)) を
+// ln fについてレーベンバーグ・マーカート法でフィットします。
+// 初期値は範囲を前後半に分けて1温度でフィットした値で、T1 < T2に並べて返します。
+func fitMaxwell2(points []fitPoint) fitResult {
+	if len(points) < 6 {
+		return fitResult{}
+	}
+	half := len(points) / 2
+	a1, s1, _, ok1 := fitExponent(points[:half], math.Sqrt)
+	a2, s2, _, ok2 := fitExponent(points[half:], math.Sqrt)
+	if !ok1 || !ok2 {
+		return fitResult{}
+	}
+	params := []float64{a1, -1 / s1, a2, -1 / s2}
+
+	// ln fと、パラメータについての微分
+	model := func(p []float64, energy float64) (float64, []float64) {
+		u1, u2 := p[0]-energy/p[1], p[2]-energy/p[3]
+		top := math.Max(u1, u2)
+		e1, e2 := math.Exp(u1-top), math.Exp(u2-top)
+		w1, w2 := e1/(e1+e2), e2/(e1+e2)
+		value := 0.5*math.Log(energy) + top + math.Log(e1+e2)
+		return value, []float64{w1, w1 * energy / (p[1] * p[1]), w2, w2 * energy / (p[3] * p[3])}
+	}
+	chi2 := func(p []float64) float64 {
+		var sum float64
+		for _, point := range points {
+			value, _ := model(p, point.energy)
+			r := math.Log(point.density) - value
+			sum += point.weight * r * r
+		}
+		return sum
+	}
+	normal := func(p []float64) ([][]float64, []float64) {
+		a := make([][]float64, len(p))
+		for i := range a {
+			a[i] = make([]float64, len(p))
+		}
+		b := make([]float64, len(p))
+		for _, point := range points {
+			value, d := model(p, point.energy)
+			r := math.Log(point.density) - value
+			for i := range p {
+				b[i] += point.weight * d[i] * r
+				for j := range p {
+					a[i][j] += point.weight * d[i] * d[j]
+				}
+			}
+		}
+		return a, b
+	}
+
+	lambda := 1e-3
+	current := chi2(params)
+	for iteration := 0; iteration < 200; iteration++ {
+		a, b := normal(params)
+		for i := range a {
+			a[i][i] *= 1 + lambda
+		}
+		step, ok := solve(a, b)
+		if !ok {
+			break
+		}
+		trial := make([]float64, len(params))
+		for i := range params {
+			trial[i] = params[i] + step[i]
+		}
+		if trial[1] <= 0 || trial[3] <= 0 {
+			lambda *= 10
+			continue
+		}
+		next := chi2(trial)
+		if next >= current {
+			lambda *= 10
+			if lambda > 1e+10 {
+				break
+			}
+			continue
+		}
+		converged := current-next < 1e-10*current
+		params, current = trial, next
+		lambda /= 10
+		if converged {
+			break
+		}
+	}
+
+	a, _ := normal(params)
+	covariance, ok := invert(a)
+	if !ok {
+		return fitResult{}
+	}
+	scale := current / float64(len(points)-len(params))
+	errors := []float64{math.Sqrt(covariance[1][1] * scale), math.Sqrt(covariance[3][3] * scale)}
+	temperatures := []float64{params[1], params[3]}
+	if temperatures[0] > temperatures[1] {
+		temperatures[0], temperatures[1] = temperatures[1], temperatures[0]
+		errors[0], errors[1] = errors[1], errors[0]
+	}
+	return fitResult{
+		temperatures: temperatures,
+		errors:       errors,
+		curve: func(energy float64) float64 {
+			value, _ := model(params, energy)
+			return math.Exp(value)
+		},
+	}
+}
+
+// 連立一次方程式 a x = b を解きます。
+func solve(a [][]float64, b []float64) ([]float64, bool) {
+	inverse, ok := invert(a)
+	if !ok {
+		return nil, false
+	}
+	x := make([]float64, len(b))
+	for i := range x {
+		for j := range b {
+			x[i] += inverse[i][j] * b[j]
+		}
+	}
+	return x, true
+}
+
+// 正方行列の逆行列をガウス・ジョルダン法で求めます。
+func invert(a [][]float64) ([][]float64, bool) {
+	n := len(a)
+	m := make([][]float64, n)
+	for i := range m {
+		m[i] = make([]float64, 2*n)
+		copy(m[i], a[i])
+		m[i][n+i] = 1
+	}
+	for col := 0; col < n; col++ {
+		pivot := col
+		for row := col + 1; row < n; row++ {
+			if math.Abs(m[row][col]) > math.Abs(m[pivot][col]) {
+				pivot = row
+			}
+		}
+		if m[pivot][col] == 0 {
+			return nil, false
+		}
+		m[col], m[pivot] = m[pivot], m[col]
+		p := m[col][col]
+		for j := range m[col] {
+			m[col][j] /= p
+		}
+		for row := 0; row < n; row++ {
+			if row == col {
+				continue
+			}
+			f := m[row][col]
+			for j := range m[row] {
+				m[row][j] -= f * m[col][j]
+			}
+		}
+	}
+	inverse := make([][]float64, n)
+	for i := range inverse {
+		inverse[i] = m[i][n:]
+	}
+	return inverse, true
+}
+
+// エネルギー分布と、モデルごとのフィットした曲線を書き出します。フィットできなかったモデルはNaNです。
+func writeFitCurve(s spectrum, log bool, models []string, results []fitResult, fileName string, wg *sync.WaitGroup) {
+	fout, err := os.Create(fileName)
+	defer fout.Close()
+	if err != nil {
+		panic(err)
+	}
+	writer := bufio.NewWriter(fout)
+	header := []string{"energy(eV)", "dN/dE(1/eV)"}
+	for _, model := range models {
+		header = append(header, model+"(1/eV)")
+	}
+	writer.WriteString("# " + strings.Join(header, " ") + "\n")
+	for i := range s.population {
+		energy := s.centre(i, log)
+		values := []string{fmt.Sprint(energy), fmt.Sprint(s.density(i))}
+		for _, result := range results {
+			v := math.NaN()
+			if result.curve != nil && energy > 0 {
+				v = result.curve(energy)
+			}
+			values = append(values, fmt.Sprint(v))
+		}
+		writer.WriteString(strings.Join(values, " ") + "\n")
+	}
+	writer.Flush()
+	wg.Done()
+}
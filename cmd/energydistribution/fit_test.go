@@ -0,0 +1,93 @@
+package energydistribution
+
+import (
+	"math"
+	"testing"
+)
+
+// 対数ビンの代表エネルギーfrom-to(eV)でdN/dE = f(E)の分布を作ります。粒子数はf(E)にビン幅をかけた値です。
+func syntheticPoints(from float64, to float64, n int, f func(energy float64) float64) []fitPoint {
+	points := []fitPoint{}
+	ratio := math.Pow(to/from, 1/float64(n-1))
+	for i := 0; i < n; i++ {
+		energy := from * math.Pow(ratio, float64(i))
+		density := f(energy)
+		points = append(points, fitPoint{energy, density, density * energy * (ratio - 1)})
+	}
+	return points
+}
+
+func TestFitModel(t *testing.T) {
+	const restEnergy = 511.0e+3
+	const temperature = 50.0e+3
+	tests := []struct {
+		model string
+		shape func(energy float64) float64
+	}{
+		{"exp", func(float64) float64 { return 1 }},
+		{"maxwell", math.Sqrt},
+		{"juttner", func(energy float64) float64 {
+			gamma := 1 + energy/restEnergy
+			return gamma * math.Sqrt(gamma*gamma-1)
+		}},
+	}
+	for _, tt := range tests {
+		points := syntheticPoints(1e+3, 1e+6, 40, func(energy float64) float64 {
+			return 1e+8 * tt.shape(energy) * math.Exp(-energy/temperature)
+		})
+		result := fitModel(tt.model, points, restEnergy)
+		if len(result.temperatures) != 1 {
+			t.Errorf("%s: fit failed", tt.model)
+			continue
+		}
+		if math.Abs(result.temperatures[0]-temperature) > 1e-6*temperature {
+			t.Errorf("%s: T = %g, want %g", tt.model, result.temperatures[0], temperature)
+		}
+		energy := 200.0e+3
+		if want := 1e+8 * tt.shape(energy) * math.Exp(-energy/temperature); math.Abs(result.curve(energy)-want) > 1e-6*want {
+			t.Errorf("%s: curve(%g) = %g, want %g", tt.model, energy, result.curve(energy), want)
+		}
+	}
+}
+
+func TestFitModelNoisy(t *testing.T) {
+	const temperature = 20.0e+3
+	// ビンごとに±5%の決まった揺らぎを加えても温度は数%以内で求まる
+	i := 0
+	points := syntheticPoints(1e+3, 2e+5, 50, func(energy float64) float64 {
+		i++
+		return (1 + 0.05*math.Sin(float64(i)*2.3)) * math.Sqrt(energy) * math.Exp(-energy/temperature)
+	})
+	result := fitModel("maxwell", points, 511.0e+3)
+	if len(result.temperatures) != 1 || math.Abs(result.temperatures[0]-temperature) > 0.03*temperature {
+		t.Fatalf("T = %v, want %g", result.temperatures, temperature)
+	}
+	if result.errors[0] <= 0 {
+		t.Errorf("error = %g, want a positive estimate", result.errors[0])
+	}
+}
+
+func TestFitMaxwell2(t *testing.T) {
+	const cold, hot = 10.0e+3, 1.0e+6
+	points := syntheticPoints(1e+3, 2e+7, 60, func(energy float64) float64 {
+		return math.Sqrt(energy) * (1e+6*math.Exp(-energy/cold) + 1e+2*math.Exp(-energy/hot))
+	})
+	result := fitModel("maxwell2", points, 511.0e+3)
+	if len(result.temperatures) != 2 {
+		t.Fatal("fit failed")
+	}
+	for k, want := range []float64{cold, hot} {
+		if math.Abs(result.temperatures[k]-want) > 0.01*want {
+			t.Errorf("T%d = %g, want %g", k+1, result.temperatures[k], want)
+		}
+	}
+}
+
+func TestFitTooFewPoints(t *testing.T) {
+	points := syntheticPoints(1e+3, 1e+4, 2, func(energy float64) float64 { return math.Exp(-energy / 1e+3) })
+	for _, model := range []string{"exp", "maxwell", "juttner", "maxwell2"} {
+		if result := fitModel(model, points, 511.0e+3); result.temperatures != nil {
+			t.Errorf("%s: fitted %v from two points", model, result.temperatures)
+		}
+	}
+}
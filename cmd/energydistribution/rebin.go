@@ -0,0 +1,258 @@
+package energydistribution
+
+import (
+	"bufio"
+	"fmt"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/Penpen7/goplot/cmd/plotconfig"
+	"github.com/Penpen7/goplot/cmd/plotscript"
+	"github.com/Penpen7/goplot/cmd/simulationconfig"
+)
+
+// エネルギー分布の後処理の指定。
+// binsはビンの境界(eV)で、nilなら最初のステップのビンを使います。logは対数ビンであることを表します。
+// unitは出力のエネルギーの単位、averageは平均するステップ数です。
+type rebinSpec struct {
+	bins       []float64
+	log        bool
+	unit       string
+	scale      float64
+	cumulative bool
+	average    int
+}
+
+// 平均をとるために残しておく最近のステップの分布
+type rebinHistory struct {
+	bins        []float64
+	log         bool
+	populations [][]float64
+	times       []float32
+	fileIDs     []int
+}
+
+var (
+	rebinMutex     sync.Mutex
+	rebinHistories = map[string]*rebinHistory{}
+	// 警告を出力ごと、種類ごとに一度だけ出すため
+	rebinWarned = map[string]bool{}
+)
+
+// keyの出力について種類kindの警告をまだ出していなければ出します。rebinMutexを持って呼び出します。
+func warnRebinOnce(key string, kind string, message string) {
+	if rebinWarned[key+" "+kind] {
+		return
+	}
+	rebinWarned[key+" "+kind] = true
+	fmt.Printf("\n\x1b[35mwarning : %s : %s\x1b[0m\n", key, message)
+}
+
+// plot.jsonのRebinのCenterを解釈します。
+//
+//	bins=log:1keV:100MeV:60   1keVから100MeVを対数で60等分したビン
+//	bins=lin:0:20MeV:100      0から20MeVを線形で100等分したビン
+//	unit=MeV                  出力のエネルギーの単位(eV, keV, MeV, GeV)
+//	cumulative                E以上の粒子数N(>E)の列を加える
+//	average=5                 最近の5ステップの平均を出力する
+func parseRebinSpec(center string) (rebinSpec, error) {
+	spec := rebinSpec{unit: "eV", scale: 1, average: 1}
+	for _, v := range strings.Fields(center) {
+		key, value, _ := strings.Cut(v, "=")
+		switch key {
+		case "bins":
+			bins, log, err := parseBins(value)
+			if err != nil {
+				return spec, err
+			}
+			spec.bins, spec.log = bins, log
+		case "unit":
+			scale, err := parseEnergy("1" + value)
+			if err != nil {
+				return spec, fmt.Errorf("invalid unit: %s", v)
+			}
+			spec.unit, spec.scale = value, scale
+		case "cumulative":
+			spec.cumulative = true
+		case "average":
+			n, err := strconv.Atoi(value)
+			if err != nil || n < 1 {
+				return spec, fmt.Errorf("invalid average: %s", v)
+			}
+			spec.average = n
+		default:
+			return spec, fmt.Errorf("invalid rebin option: %s", v)
+		}
+	}
+	return spec, nil
+}
+
+// lin:from:to:nまたはlog:from:to:nのビンの境界(eV)を返します。
+// logは対数ビンであることを表します。
+func parseBins(value string) (bins []float64, log bool, err error) {
+	terms := strings.Split(value, ":")
+	if len(terms) != 4 || (terms[0] != "lin" && terms[0] != "log") {
+		return nil, false, fmt.Errorf("invalid bins: %s", value)
+	}
+	log = terms[0] == "log"
+	from, err := parseEnergy(terms[1])
+	if err != nil {
+		return nil, false, err
+	}
+	to, err := parseEnergy(terms[2])
+	if err != nil {
+		return nil, false, err
+	}
+	n, err := strconv.Atoi(terms[3])
+	if err != nil || n < 1 || to <= from || (log && from <= 0) {
+		return nil, false, fmt.Errorf("invalid bins: %s", value)
+	}
+	bins = make([]float64, n+1)
+	for k := range bins {
+		if log {
+			bins[k] = from * math.Pow(to/from, float64(k)/float64(n))
+		} else {
+			bins[k] = from + (to-from)*float64(k)/float64(n)
+		}
+	}
+	return bins, log, nil
+}
+
+// 分布を境界binsのビンに分け直します。元のビンの中では粒子が一様に分布しているとみなし、
+// 重なった幅に比例して配ります。
+func rebin(s spectrum, bins []float64) []float64 {
+	population := make([]float64, len(bins)-1)
+	for i, v := range s.population {
+		width := s.high[i] - s.low[i]
+		if v == 0 || width <= 0 {
+			continue
+		}
+		for k := range population {
+			overlap := math.Min(s.high[i], bins[k+1]) - math.Max(s.low[i], bins[k])
+			if overlap > 0 {
+				population[k] += float64(v) * overlap / width
+			}
+		}
+	}
+	return population
+}
+
+// plot.jsonのRebinに粒子種speciesのエネルギー分布quantityの指定があれば、指定したビンに分け直し、
+// 最近のステップで平均した分布を書き出します。logは元の分布が対数ビンであることを表します。
+// 対数ビンの分布は最大エネルギーに合わせてステップごとにビンが変わるため、平均するときはbins=が必要です。
+// 分け直したビンの外に出た粒子があれば警告します。
+func rebinSpectrum(s spectrum, log bool, quantity string, config simulationconfig.SimulationConfig, plotConfig plotconfig.Art, fileID int, species int32, simulationTime float32, wg *sync.WaitGroup) {
+	subart, name, found := findSpectrumSubart(plotConfig.Rebin, quantity, config, plotConfig, species)
+	if !found || !subart.Plot || len(s.population) == 0 {
+		return
+	}
+	spec, err := parseRebinSpec(subart.Center)
+	if err != nil {
+		fmt.Println("Warning:", name, err)
+		return
+	}
+
+	rebinMutex.Lock()
+	key := fmt.Sprintf("%s_is=%02d", name, species)
+	if spec.bins == nil && log && spec.average > 1 {
+		warnRebinOnce(key, "bins", "対数ビンの分布を平均するにはbins=でビンを指定してください。分け直しを出力しません。")
+		rebinMutex.Unlock()
+		return
+	}
+	history, exists := rebinHistories[key]
+	if !exists {
+		history = &rebinHistory{bins: spec.bins, log: spec.log}
+		if history.bins == nil {
+			history.bins, history.log = append(append([]float64{}, s.low...), s.high[len(s.high)-1]), log
+		}
+		rebinHistories[key] = history
+	}
+	population := rebin(s, history.bins)
+	total, inside := 0.0, 0.0
+	for _, v := range s.population {
+		total += float64(v)
+	}
+	for _, v := range population {
+		inside += v
+	}
+	if total > 0 && (total-inside)/total > 1e-6 {
+		warnRebinOnce(key, "outside", fmt.Sprintf("ステップ%dで粒子数の%.3g%%が分け直したビンの外にあります。bins=で範囲を広げてください。", fileID, (total-inside)/total*100))
+	}
+	history.populations = append(history.populations, population)
+	history.times = append(history.times, simulationTime)
+	history.fileIDs = append(history.fileIDs, fileID)
+	if len(history.populations) > spec.average {
+		history.populations = history.populations[1:]
+		history.times = history.times[1:]
+		history.fileIDs = history.fileIDs[1:]
+	}
+	bins, logBins := history.bins, history.log
+	average := make([]float64, len(bins)-1)
+	for _, population := range history.populations {
+		for k, v := range population {
+			average[k] += v / float64(len(history.populations))
+		}
+	}
+	header := fmt.Sprintf("# %s : average over %d steps (step %d-%d, time %g-%g)\n",
+		key, len(history.populations), history.fileIDs[0], fileID, history.times[0], simulationTime)
+	rebinMutex.Unlock()
+
+	rebinName := name + "_Rebin"
+	wg.Add(1)
+	go writeRebinned(bins, logBins, average, spec, header, fmt.Sprintf("%s/%s%04d_is=%02d.txt", plotConfig.OutputASCIIDirectory, rebinName, fileID, species), wg)
+	columns := []plotscript.Column{{Name: "energy", Unit: spec.unit}, {Name: "population"}, {Name: "energy_low", Unit: spec.unit},
+		{Name: "energy_high", Unit: spec.unit}, {Name: "dN/dE", Unit: "1/" + spec.unit}}
+	if spec.cumulative {
+		columns = append(columns, plotscript.Column{Name: "N(>E)"})
+	}
+	plotscript.Register(plotscript.Kind{
+		Name:    fmt.Sprintf("%s_is=%02d", rebinName, species),
+		Pattern: fmt.Sprintf("%s%%04d_is=%02d.txt", rebinName, species),
+		Format:  plotscript.Line,
+		Columns: columns,
+		LogX:    logBins,
+		LogY:    true,
+	}, fileID)
+}
+
+// 分け直した分布を書き出します。代表エネルギーは線形ビンでは中点、対数ビンでは幾何平均で、
+// N(>E)はビンの下端E以上の粒子数です。
+func writeRebinned(bins []float64, log bool, population []float64, spec rebinSpec, header string, fileName string, wg *sync.WaitGroup) {
+	fout, err := os.Create(fileName)
+	defer fout.Close()
+	if err != nil {
+		panic(err)
+	}
+	writer := bufio.NewWriter(fout)
+	writer.WriteString(header)
+	columns := fmt.Sprintf("# energy(%s) population energy_low(%s) energy_high(%s) dN/dE(1/%s)", spec.unit, spec.unit, spec.unit, spec.unit)
+	if spec.cumulative {
+		columns += " N(>E)"
+	}
+	writer.WriteString(columns + "\n")
+	above := make([]float64, len(population)+1)
+	for k := len(population) - 1; k >= 0; k-- {
+		above[k] = above[k+1] + population[k]
+	}
+	for k, v := range population {
+		low, high := bins[k]/spec.scale, bins[k+1]/spec.scale
+		centre := (low + high) / 2
+		if log {
+			centre = math.Sqrt(low * high)
+		}
+		density := 0.0
+		if high > low {
+			density = v / (high - low)
+		}
+		values := []string{fmt.Sprint(centre), fmt.Sprint(v), fmt.Sprint(low), fmt.Sprint(high), fmt.Sprint(density)}
+		if spec.cumulative {
+			values = append(values, fmt.Sprint(above[k]))
+		}
+		writer.WriteString(strings.Join(values, " ") + "\n")
+	}
+	writer.Flush()
+	wg.Done()
+}
@@ -0,0 +1,66 @@
+package energydistribution
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"os"
+	"sync"
+
+	"github.com/Penpen7/goplot/cmd/fortbin"
+	"github.com/Penpen7/goplot/cmd/plotconfig"
+	"github.com/Penpen7/goplot/cmd/simulationconfig"
+)
+
+// 粒子種ごとのエネルギー分布の後に続くレコードの数。
+// 中身はシミュレーション側の出力処理で確かめていないため解釈せず、Energy_Recordsでそのまま出力します。
+const trailingRecords = 12
+
+// 1つのレコードを大きさに合わせてfloat32の配列として読み込みます。
+func readRecord(file *os.File) []float32 {
+	chunk := fortbin.ReadNextChunk(file)
+	if chunk == nil {
+		return nil
+	}
+	values := make([]float32, chunk.Len()/4)
+	binary.Read(chunk, binary.LittleEndian, &values)
+	return values
+}
+
+// plot.jsonのEnergyDistributionでnameの出力が有効かを返します。
+func enabled(plotConfig plotconfig.Art, name string) bool {
+	subart, found := plotconfig.FindSubart(plotConfig.EnergyDistribution, name)
+	return found && subart.Plot
+}
+
+// エネルギー分布の後に続くレコードを読み込み、plot.jsonのEnergyDistributionでEnergy_Recordsが有効なら
+// すべてのレコードをそのまま書き出します。
+func loadWriteRecords(file *os.File, config simulationconfig.SimulationConfig, plotConfig plotconfig.Art, fileID int, species int32, wg *sync.WaitGroup) {
+	records := make([][]float32, trailingRecords)
+	for i := range records {
+		records[i] = readRecord(file)
+	}
+	if enabled(plotConfig, "Energy_Records") {
+		wg.Add(1)
+		go writeRecords(records, fmt.Sprintf("%s/%s_Energy_Records%04d_is=%02d.txt", plotConfig.OutputASCIIDirectory, plotconfig.SpeciesName(plotConfig, config, species), fileID, species), wg)
+	}
+}
+
+// レコードをそのまま書き出します。レコードごとに空行で区切ります。
+func writeRecords(records [][]float32, fileName string, wg *sync.WaitGroup) {
+	fout, err := os.Create(fileName)
+	defer fout.Close()
+	if err != nil {
+		panic(err)
+	}
+	writer := bufio.NewWriter(fout)
+	for r, record := range records {
+		writer.WriteString(fmt.Sprintf("# record %02d (%d values) : index value\n", r, len(record)))
+		for i, v := range record {
+			writer.WriteString(fmt.Sprintln(i, v))
+		}
+		writer.WriteString("\n")
+	}
+	writer.Flush()
+	wg.Done()
+}
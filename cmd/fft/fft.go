@@ -0,0 +1,119 @@
+package fft
+
+import (
+	"math"
+	"math/cmplx"
+)
+
+// 離散フーリエ変換 F[k] = Σ f[n] exp(-2πikn/N) を求めます。
+// 長さが2のべき乗であれば基数2のFFT、それ以外はBluesteinのアルゴリズムを使います。
+func Transform(data []complex128) []complex128 {
+	n := len(data)
+	if n <= 1 {
+		return append([]complex128{}, data...)
+	}
+	if n&(n-1) == 0 {
+		return radix2(data, false)
+	}
+	return bluestein(data)
+}
+
+// 基数2のFFT。inverseがtrueのときは正規化しない逆変換になります。
+func radix2(data []complex128, inverse bool) []complex128 {
+	n := len(data)
+	res := make([]complex128, n)
+	bits := 0
+	for 1<<bits < n {
+		bits++
+	}
+	for i := range data {
+		j := 0
+		for b := 0; b < bits; b++ {
+			if i&(1<<b) != 0 {
+				j |= 1 << (bits - 1 - b)
+			}
+		}
+		res[j] = data[i]
+	}
+	sign := -1.0
+	if inverse {
+		sign = 1.0
+	}
+	for size := 2; size <= n; size <<= 1 {
+		w := cmplx.Exp(complex(0, sign*2*math.Pi/float64(size)))
+		for start := 0; start < n; start += size {
+			wk := complex(1, 0)
+			for k := 0; k < size/2; k++ {
+				even := res[start+k]
+				odd := res[start+k+size/2] * wk
+				res[start+k] = even + odd
+				res[start+k+size/2] = even - odd
+				wk *= w
+			}
+		}
+	}
+	return res
+}
+
+// 任意の長さのDFTを、2のべき乗の長さの畳み込みに帰着させて求めます。
+func bluestein(data []complex128) []complex128 {
+	n := len(data)
+	m := 1
+	for m < 2*n-1 {
+		m <<= 1
+	}
+	chirp := make([]complex128, n)
+	for k := 0; k < n; k++ {
+		// k^2が大きくなると誤差が出るので 2N で剰余をとる
+		angle := math.Pi * float64((k*k)%(2*n)) / float64(n)
+		chirp[k] = cmplx.Exp(complex(0, -angle))
+	}
+	a := make([]complex128, m)
+	b := make([]complex128, m)
+	for k := 0; k < n; k++ {
+		a[k] = data[k] * chirp[k]
+	}
+	b[0] = cmplx.Conj(chirp[0])
+	for k := 1; k < n; k++ {
+		b[k] = cmplx.Conj(chirp[k])
+		b[m-k] = cmplx.Conj(chirp[k])
+	}
+	fa := radix2(a, false)
+	fb := radix2(b, false)
+	for i := range fa {
+		fa[i] *= fb[i]
+	}
+	conv := radix2(fa, true)
+	res := make([]complex128, n)
+	for k := 0; k < n; k++ {
+		res[k] = conv[k] / complex(float64(m), 0) * chirp[k]
+	}
+	return res
+}
+
+// 窓関数を返します。nameはhann, hamming, none(矩形窓)のいずれかです。
+func Window(name string, n int) []float64 {
+	w := make([]float64, n)
+	for i := range w {
+		w[i] = 1.0
+		if n <= 1 {
+			continue
+		}
+		phase := 2 * math.Pi * float64(i) / float64(n-1)
+		switch name {
+		case "hann":
+			w[i] = 0.5 - 0.5*math.Cos(phase)
+		case "hamming":
+			w[i] = 0.54 - 0.46*math.Cos(phase)
+		}
+	}
+	return w
+}
+
+// 周波数の番号kを、負の周波数を含む番号に変換します。Nが偶数なら -N/2 ... N/2-1、奇数なら -(N-1)/2 ... (N-1)/2 です。
+func SignedIndex(k int, n int) int {
+	if k >= (n+1)/2 {
+		return k - n
+	}
+	return k
+}
@@ -0,0 +1,55 @@
+package fft
+
+import (
+	"math"
+	"math/cmplx"
+	"testing"
+)
+
+// 定義どおりに O(N^2) で求めた離散フーリエ変換
+func naiveDFT(data []complex128) []complex128 {
+	n := len(data)
+	res := make([]complex128, n)
+	for k := range res {
+		for j, v := range data {
+			res[k] += v * cmplx.Exp(complex(0, -2*math.Pi*float64(k*j%n)/float64(n)))
+		}
+	}
+	return res
+}
+
+func TestTransform(t *testing.T) {
+	for _, n := range []int{1, 2, 4, 8, 64, 3, 5, 6, 12, 17, 100} {
+		data := make([]complex128, n)
+		for i := range data {
+			data[i] = complex(math.Sin(1.7*float64(i))+0.3*float64(i%3), math.Cos(0.9*float64(i*i)))
+		}
+		got, want := Transform(data), naiveDFT(data)
+		if len(got) != n {
+			t.Fatalf("n=%d: len = %d", n, len(got))
+		}
+		for k := range want {
+			if cmplx.Abs(got[k]-want[k]) > 1e-9*float64(n) {
+				t.Errorf("n=%d: F[%d] = %v, want %v", n, k, got[k], want[k])
+			}
+		}
+	}
+}
+
+func TestSignedIndex(t *testing.T) {
+	tests := []struct {
+		n    int
+		want []int
+	}{
+		{4, []int{0, 1, -2, -1}},
+		{5, []int{0, 1, 2, -2, -1}},
+		{6, []int{0, 1, 2, -3, -2, -1}},
+	}
+	for _, tt := range tests {
+		for k, want := range tt.want {
+			if got := SignedIndex(k, tt.n); got != want {
+				t.Errorf("SignedIndex(%d, %d) = %d, want %d", k, tt.n, got, want)
+			}
+		}
+	}
+}
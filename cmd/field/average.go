@@ -0,0 +1,195 @@
+package field
+
+import (
+	"bufio"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// 帯ごとの平均の取り方。
+// keepの軸の格子点ごとに、それ以外の軸についてbandsの各範囲[lo, hi]で平均します。
+// 平均する軸が複数あるときは、各軸の帯の組み合わせごとに1列になります。
+type averageSpec struct {
+	keep  []int
+	bands [3][][2]int
+}
+
+// 以前のモード名。新しい書式の別名として残します。
+var averageAliases = map[string]string{
+	"xaverage":      "avg@y=8bands,z=mid",
+	"zxaverage":     "avg@y",
+	"whole_average": "avg@x,y,z",
+}
+
+func isAverageMode(mode string) bool {
+	_, isAlias := averageAliases[mode]
+	return isAlias || strings.HasPrefix(mode, "avg@")
+}
+
+// 帯ごとの平均のモードを解釈します。
+// avg@に続けて平均する軸を,で区切って並べます。並べなかった軸は出力の座標として残ります。
+// 位置の書式はparseCoordinateと同じです。
+//
+//	avg@y                 y方向全体の平均(x, zの2次元)
+//	avg@y=8bands,z=mid    z=中心で、yを8等分した帯ごとの平均(xの1次元、8列)
+//	avg@y=0:15+16:31      yが0から15, 16から31の平均(2列)
+//	avg@y=2um:4um         yが2µmから4µmの平均
+//	avg@x,y,z             全体の平均(1つの値)
+func parseAverageSpec(mode string, size [3]int) (averageSpec, error) {
+	if alias, isAlias := averageAliases[mode]; isAlias {
+		spec, err := parseAverageSpec(alias, size)
+		if mode == "zxaverage" {
+			// 以前と同じくz, xの順に出力する
+			spec.keep = []int{2, 0}
+		}
+		return spec, err
+	}
+	terms, found := strings.CutPrefix(mode, "avg@")
+	if !found || terms == "" {
+		return averageSpec{}, fmt.Errorf("invalid mode: %s", mode)
+	}
+	var spec averageSpec
+	for _, v := range strings.Split(terms, ",") {
+		name, value, hasValue := strings.Cut(v, "=")
+		axis, isAxis := axisIndex(name)
+		if !isAxis {
+			return averageSpec{}, fmt.Errorf("invalid axis: %s", v)
+		}
+		if spec.bands[axis] != nil {
+			return averageSpec{}, fmt.Errorf("%s軸が2回指定されています: %s", name, mode)
+		}
+		bands, err := parseBands(value, hasValue, axis, size[axis])
+		if err != nil {
+			return averageSpec{}, err
+		}
+		spec.bands[axis] = bands
+	}
+	for axis := 0; axis < 3; axis++ {
+		if spec.bands[axis] == nil {
+			spec.keep = append(spec.keep, axis)
+		}
+	}
+	return spec, nil
+}
+
+// 1つの軸の帯の指定を解釈します。
+func parseBands(value string, hasValue bool, axis int, size int) ([][2]int, error) {
+	if !hasValue || value == "all" {
+		return [][2]int{{0, size - 1}}, nil
+	}
+	if n, isCount := strings.CutSuffix(value, "bands"); isCount {
+		count, err := strconv.Atoi(n)
+		if err != nil || count < 1 || count > size {
+			return nil, fmt.Errorf("invalid band number: %s", value)
+		}
+		bands := [][2]int{}
+		for k := 0; k < count; k++ {
+			bands = append(bands, [2]int{k * size / count, (k+1)*size/count - 1})
+		}
+		return bands, nil
+	}
+	bands := [][2]int{}
+	for _, r := range strings.Split(value, "+") {
+		from, to, isRange := strings.Cut(r, ":")
+		if !isRange {
+			to = from
+		}
+		lo, err := parsePosition(from, axis, size)
+		if err != nil {
+			return nil, err
+		}
+		hi, err := parsePosition(to, axis, size)
+		if err != nil {
+			return nil, err
+		}
+		if lo > hi {
+			lo, hi = hi, lo
+		}
+		bands = append(bands, [2]int{lo, hi})
+	}
+	return bands, nil
+}
+
+// 帯の組み合わせごとの平均範囲。keepの軸は0のままです。
+func (spec averageSpec) boxes() [][2][3]int {
+	boxes := [][2][3]int{{}}
+	for axis := 0; axis < 3; axis++ {
+		if spec.bands[axis] == nil {
+			continue
+		}
+		next := [][2][3]int{}
+		for _, box := range boxes {
+			for _, band := range spec.bands[axis] {
+				box[0][axis], box[1][axis] = band[0], band[1]
+				next = append(next, box)
+			}
+		}
+		boxes = next
+	}
+	return boxes
+}
+
+// 列の名前。平均した範囲を"y=0:7,z=32"の形で表します。
+func (spec averageSpec) columnNames() []string {
+	names := []string{}
+	for _, box := range spec.boxes() {
+		parts := []string{}
+		for axis := 0; axis < 3; axis++ {
+			if spec.bands[axis] == nil {
+				continue
+			}
+			if box[0][axis] == box[1][axis] {
+				parts = append(parts, fmt.Sprintf("%s=%d", axisName[axis], box[0][axis]))
+			} else {
+				parts = append(parts, fmt.Sprintf("%s=%d:%d", axisName[axis], box[0][axis], box[1][axis]))
+			}
+		}
+		names = append(names, strings.Join(parts, ","))
+	}
+	return names
+}
+
+// 帯ごとの平均を書き出します。1行目に列の説明を書き、
+// 残した軸の格子番号に続けて帯の組み合わせごとの平均を並べます。
+// 残した軸が2つのときは1つ目の軸ごとに空行で区切ります。
+func writeAverage(writer *bufio.Writer, g [][][]float32, mode string, spec averageSpec) {
+	size := [3]int{len(g), len(g[0]), len(g[0][0])}
+	columns := []string{}
+	for _, axis := range spec.keep {
+		columns = append(columns, axisName[axis])
+	}
+	columns = append(columns, spec.columnNames()...)
+	writer.WriteString(fmt.Sprintf("# %s : %s\n", strings.Join(columns, " "), mode))
+
+	boxes := spec.boxes()
+	row := func(index []int) {
+		values := []string{}
+		for _, i := range index {
+			values = append(values, strconv.Itoa(i))
+		}
+		for _, box := range boxes {
+			lo, hi := box[0], box[1]
+			for k, axis := range spec.keep {
+				lo[axis], hi[axis] = index[k], index[k]
+			}
+			values = append(values, fmt.Sprint(reduceBox(g, lo, hi, "avg")))
+		}
+		writer.WriteString(strings.Join(values, " ") + "\n")
+	}
+	switch len(spec.keep) {
+	case 0:
+		row(nil)
+	case 1:
+		for i := 0; i < size[spec.keep[0]]; i++ {
+			row([]int{i})
+		}
+	case 2:
+		for i := 0; i < size[spec.keep[0]]; i++ {
+			for j := 0; j < size[spec.keep[1]]; j++ {
+				row([]int{i, j})
+			}
+			writer.WriteString("\n")
+		}
+	}
+}
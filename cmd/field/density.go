@@ -0,0 +1,94 @@
+package field
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/Penpen7/goplot/cmd/physconst"
+	"github.com/Penpen7/goplot/cmd/plotconfig"
+)
+
+var densityWarning sync.Once
+
+func isDensity(quantity string) bool {
+	return quantity == "Density" || quantity == "IonDensity" || quantity == "ElectronDensity"
+}
+
+// plot.jsonのDensityUnitが使える単位かを確かめます。
+func CheckDensityUnit(unit string) error {
+	switch unit {
+	case "raw", "nc", "cm-3":
+		return nil
+	}
+	return fmt.Errorf("unknown DensityUnit: %s", unit)
+}
+
+// 物理量quantityがplot.jsonのDensityUnitで出力する密度であれば、換算の倍率、単位と、
+// その単位での臨界密度(分からなければ0)を返します。密度以外は倍率1で、fieldUnitの単位を返します。
+// quantityはElectron_Density_is=02のような粒子種つきの名前でも構いません。
+func densityScale(quantity string, plotConfig plotconfig.Art) (float32, string, float32) {
+	base, _, _ := strings.Cut(quantity, "_is=")
+	if i := strings.LastIndex(base, "_"); i >= 0 && isDensity(base[i+1:]) {
+		base = base[i+1:]
+	}
+	if !isDensity(base) {
+		unit, found := fieldUnit[base]
+		if !found {
+			unit = "normalized"
+		}
+		return 1, unit, 0
+	}
+	critical := float32(0)
+	if physconst.NormalizedNumberDensity > 0 {
+		critical = physconst.CriticalDensity / physconst.NormalizedNumberDensity
+	}
+	scale, unit := float32(1), "normalized"
+	switch plotConfig.DensityUnit {
+	case "nc":
+		if critical == 0 {
+			densityWarning.Do(func() {
+				fmt.Println("Warning:レーザーの波長が不明なため、密度を規格化単位で出力します")
+			})
+			break
+		}
+		scale, unit = 1/critical, "n_c"
+	case "cm-3":
+		scale, unit = physconst.NormalizedNumberDensity, "cm^-3"
+	}
+	return scale, unit, critical * scale
+}
+
+// 物理量quantityの値vをplot.jsonのDensityUnitに換算した値と単位を返します。密度以外はそのまま返します。
+// プローブなど、1点の値だけを出力するときに使います。
+func InDensityUnit(v float32, quantity string, plotConfig plotconfig.Art) (float32, string) {
+	scale, unit, _ := densityScale(quantity, plotConfig)
+	return v * scale, unit
+}
+
+// 密度であればplot.jsonのDensityUnitに換算した配列を返します。
+// 戻り値は換算後の配列、単位、その単位での臨界密度(分からなければ0)です。
+// 密度以外はそのまま返します。
+func inDensityUnit(g [][][]float32, quantity string, plotConfig plotconfig.Art) ([][][]float32, string, float32) {
+	scale, unit, critical := densityScale(quantity, plotConfig)
+	if scale == 1 {
+		return g, unit, critical
+	}
+	res := newArrayLike(g)
+	for x := range g {
+		for y := range g[x] {
+			for z := range g[x][y] {
+				res[x][y][z] = g[x][y][z] * scale
+			}
+		}
+	}
+	return res, unit, critical
+}
+
+// 臨界密度の等値線を描く画像の設定と、VTKに書くメタデータを返します。
+func criticalDensityMarks(critical float32) (contours []float64, fieldData map[string]float32) {
+	if critical <= 0 {
+		return nil, nil
+	}
+	return []float64{float64(critical)}, map[string]float32{"CriticalDensity": critical}
+}
@@ -0,0 +1,85 @@
+package field
+
+import (
+	"math"
+)
+
+const (
+	vacuumPermittivity = 8.8541878128e-12 // ε0 (F/m)
+	vacuumPermeability = 1.25663706212e-6 // μ0 (H/m)
+)
+
+// E, B, Jから計算できる派生量
+var DerivedFieldNames = [...]string{"Emag", "Bmag", "EnergyDensity", "Sx", "Sy", "Sz", "EdotJ"}
+
+// 格子点ごとに関数fを適用した3次元配列を作ります。
+func (fields FieldSet) pointwise(f func(x, y, z int) float64) [][][]float32 {
+	ex := fields["Ex"]
+	res := make([][][]float32, len(ex))
+	for x := range ex {
+		res[x] = make([][]float32, len(ex[x]))
+		for y := range ex[x] {
+			res[x][y] = make([]float32, len(ex[x][y]))
+			for z := range ex[x][y] {
+				res[x][y][z] = float32(f(x, y, z))
+			}
+		}
+	}
+	return res
+}
+
+// 格子点(x, y, z)でのベクトル量(E, B, J)の成分を返します。
+func (fields FieldSet) vector(name string, x, y, z int) [3]float64 {
+	return [3]float64{
+		float64(fields[name+"x"][x][y][z]),
+		float64(fields[name+"y"][x][y][z]),
+		float64(fields[name+"z"][x][y][z]),
+	}
+}
+
+func dot(a, b [3]float64) float64 {
+	return a[0]*b[0] + a[1]*b[1] + a[2]*b[2]
+}
+
+func cross(a, b [3]float64) [3]float64 {
+	return [3]float64{a[1]*b[2] - a[2]*b[1], a[2]*b[0] - a[0]*b[2], a[0]*b[1] - a[1]*b[0]}
+}
+
+// 読み込み済みの量、もしくは派生量を返します。派生量はEが V/m、Bが T に規格化された後の値から計算します。
+//
+//	Emag, Bmag    |E|, |B|
+//	EnergyDensity 電磁場のエネルギー密度 ε0E^2/2 + B^2/2μ0 (J/m^3)
+//	Sx, Sy, Sz    ポインティングベクトル E×B/μ0 (W/m^2)
+//	EdotJ         E・J
+func (fields FieldSet) Derived(name string) ([][][]float32, bool) {
+	if g, found := fields[name]; found {
+		return g, true
+	}
+	switch name {
+	case "Emag":
+		return fields.pointwise(func(x, y, z int) float64 {
+			e := fields.vector("E", x, y, z)
+			return math.Sqrt(dot(e, e))
+		}), true
+	case "Bmag":
+		return fields.pointwise(func(x, y, z int) float64 {
+			b := fields.vector("B", x, y, z)
+			return math.Sqrt(dot(b, b))
+		}), true
+	case "EnergyDensity":
+		return fields.pointwise(func(x, y, z int) float64 {
+			e, b := fields.vector("E", x, y, z), fields.vector("B", x, y, z)
+			return 0.5*vacuumPermittivity*dot(e, e) + 0.5*dot(b, b)/vacuumPermeability
+		}), true
+	case "Sx", "Sy", "Sz":
+		component := int(name[1] - 'x')
+		return fields.pointwise(func(x, y, z int) float64 {
+			return cross(fields.vector("E", x, y, z), fields.vector("B", x, y, z))[component] / vacuumPermeability
+		}), true
+	case "EdotJ":
+		return fields.pointwise(func(x, y, z int) float64 {
+			return dot(fields.vector("E", x, y, z), fields.vector("J", x, y, z))
+		}), true
+	}
+	return nil, false
+}
@@ -0,0 +1,90 @@
+package field
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/Penpen7/goplot/cmd/fieldop"
+	"github.com/Penpen7/goplot/cmd/plotconfig"
+	"github.com/Penpen7/goplot/cmd/plotscript"
+	"github.com/Penpen7/goplot/cmd/runtable"
+	"github.com/Penpen7/goplot/cmd/simulationconfig"
+)
+
+// 最初のステップの全エネルギー。エネルギー保存のずれの基準にします。
+var initialTotalEnergy float64
+
+// 配列の体積積分を規格化単位で求めます。
+func integrate(g [][][]float32, cellVolume float64, f func(v float64) float64) float64 {
+	sum := 0.0
+	for x := range g {
+		for y := range g[x] {
+			for z := range g[x][y] {
+				sum += f(float64(g[x][y][z]))
+			}
+		}
+	}
+	return sum * cellVolume
+}
+
+// 粒子種ごとの運動エネルギー(_Energyメッシュの体積積分、規格化単位)を返します。
+func KineticEnergies(particles ParticleMeshSet, config simulationconfig.SimulationConfig, plotConfig plotconfig.Art) map[int32]float64 {
+	grid := fieldop.NewGrid(config, plotConfig.FieldBoundary, plotConfig.FieldStaggering)
+	cellVolume := grid.Spacing[0] * grid.Spacing[1] * grid.Spacing[2]
+	kinetic := map[int32]float64{}
+	for species := int32(1); species <= config.TotalParticleSpecies; species++ {
+		kinetic[species] = integrate(particles[species]["Energy"], cellVolume, func(v float64) float64 { return v })
+	}
+	return kinetic
+}
+
+// 電磁場のエネルギー、粒子種ごとの運動エネルギーと粒子数、全エネルギーとそのずれを
+// Diagnostics.txtに1行追記します。値はすべて規格化単位です。
+// 電磁場のエネルギー密度は (E^2+B^2)/2、粒子の運動エネルギーは_Energyメッシュの体積積分、
+// 粒子数は_Densityメッシュの体積積分としています。kineticはKineticEnergiesで求めた粒子種ごとの運動エネルギーです。
+//
+// 2つのエネルギーは同じ単位 n0 Δx^3 ε0 です。n0は密度の規格化定数、Δxは長さの規格化単位、
+// ε0はエネルギーの規格化定数(physconst.NormalizedEnergy = 4π n0 e^2 Δx^2)です。
+// 電場の規格化定数が4π n0 e Δxなので、ガウス単位系のエネルギー密度 (E^2+B^2)/8π は
+// 規格化単位で (E^2+B^2)/2 × n0 ε0 になります。_Energyメッシュはエネルギー分布と同じくε0を単位とした
+// エネルギーのn0単位の密度であることを前提にしています。この前提が成り立たない出力では全エネルギーとずれは使えません。
+func WriteDiagnostics(fields FieldSet, particles ParticleMeshSet, kinetic map[int32]float64, config simulationconfig.SimulationConfig, plotConfig plotconfig.Art, fileID int, simulationTime float32) {
+	if subart, found := plotconfig.FindSubart(plotConfig.Field, "Diagnostics"); !found || !subart.Plot || fields["Ex"] == nil {
+		return
+	}
+	grid := fieldop.NewGrid(config, plotConfig.FieldBoundary, plotConfig.FieldStaggering)
+	cellVolume := grid.Spacing[0] * grid.Spacing[1] * grid.Spacing[2]
+	square := func(v float64) float64 { return 0.5 * v * v }
+	identity := func(v float64) float64 { return v }
+
+	fieldEnergy := 0.0
+	for _, name := range []string{"E", "B"} {
+		for _, g := range fields.codeVector(name) {
+			fieldEnergy += integrate(g, cellVolume, square)
+		}
+	}
+	columns := []plotscript.Column{{Name: "time", Unit: "normalized"}, {Name: "FieldEnergy", Unit: "normalized"}}
+	row := []float64{float64(simulationTime), fieldEnergy}
+	totalEnergy := fieldEnergy
+	for species := int32(1); species <= config.TotalParticleSpecies; species++ {
+		count := integrate(particles[species]["Density"], cellVolume, identity)
+		totalEnergy += kinetic[species]
+		columns = append(columns, plotscript.Column{Name: fmt.Sprintf("KineticEnergy_is=%02d", species), Unit: "normalized"},
+			plotscript.Column{Name: fmt.Sprintf("ParticleNumber_is=%02d", species), Unit: "normalized"})
+		row = append(row, kinetic[species], count)
+	}
+	if fileID == 0 {
+		initialTotalEnergy = totalEnergy
+	}
+	drift := 0.0
+	if initialTotalEnergy != 0 {
+		drift = (totalEnergy - initialTotalEnergy) / initialTotalEnergy
+	}
+	columns = append(columns, plotscript.Column{Name: "TotalEnergy", Unit: "normalized"}, plotscript.Column{Name: "RelativeDrift"})
+	row = append(row, totalEnergy, drift)
+	runtable.Append(plotConfig.OutputASCIIDirectory, "Diagnostics", fileID, columns, row)
+
+	if plotConfig.EnergyDriftThreshold > 0 && math.Abs(drift) > plotConfig.EnergyDriftThreshold {
+		fmt.Printf("\n\x1b[35mwarning : 全エネルギーが最初のステップから%.2f%%ずれています(しきい値%.2f%%)\x1b[0m\n", drift*100, plotConfig.EnergyDriftThreshold*100)
+	}
+}
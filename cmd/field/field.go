@@ -7,16 +7,160 @@ import (
 	"encoding/binary"
 	"fmt"
 	"os"
+	"sort"
+	"strconv"
 	"strings"
 	"sync"
 
+	"github.com/Penpen7/goplot/cmd/decomposition"
+	"github.com/Penpen7/goplot/cmd/fieldop"
 	"github.com/Penpen7/goplot/cmd/fortbin"
+	"github.com/Penpen7/goplot/cmd/npy"
 	"github.com/Penpen7/goplot/cmd/physconst"
 	"github.com/Penpen7/goplot/cmd/plotconfig"
+	"github.com/Penpen7/goplot/cmd/plotscript"
+	"github.com/Penpen7/goplot/cmd/render"
 	"github.com/Penpen7/goplot/cmd/simulationconfig"
+	"github.com/Penpen7/goplot/cmd/svgplot"
 	"github.com/Penpen7/goplot/cmd/utility"
 )
 
+// 各物理量の単位
+var fieldUnit = map[string]string{
+	"Ex": "V/m", "Ey": "V/m", "Ez": "V/m",
+	"Bx": "T", "By": "T", "Bz": "T",
+	"Jx": "normalized", "Jy": "normalized", "Jz": "normalized",
+	"Emag": "V/m", "Bmag": "T", "EnergyDensity": "J/m^3",
+	"Sx": "W/m^2", "Sy": "W/m^2", "Sz": "W/m^2",
+	"EdotJ": "V/m x normalized",
+	"divE":  "normalized", "divB": "normalized",
+	"curlEx": "normalized", "curlEy": "normalized", "curlEz": "normalized",
+	"curlBx": "normalized", "curlBy": "normalized", "curlBz": "normalized",
+	"CurlBminusJx": "normalized", "CurlBminusJy": "normalized", "CurlBminusJz": "normalized",
+	"GaussResidual": "normalized", "gradRhox": "normalized", "gradRhoy": "normalized", "gradRhoz": "normalized",
+	"ChargeDensity": "normalized", "IonDensity": "normalized", "ElectronDensity": "normalized", "MeanZ": "e",
+}
+
+// 1次元の出力モード(x, y, zとその位置指定、ラインアウト)であれば、
+// 横軸の名前、位置(µm)と線上の値を返します。
+func cut1D(g [][][]float32, mode string) (string, []float64, []float32, bool) {
+	size := [3]int{len(g), len(g[0]), len(g[0][0])}
+	if strings.HasPrefix(mode, "line@") {
+		l, err := parseLineout(mode, size)
+		if err != nil {
+			return "", nil, nil, false
+		}
+		distance, _, values := l.sample(g)
+		return "s", distance, values, true
+	}
+	spec, err := parseSliceSpec(mode, size)
+	if err != nil || len(spec.free) != 1 {
+		return "", nil, nil, false
+	}
+	positions := []float64{}
+	values := []float32{}
+	for i, v := range spec.extract(g) {
+		positions = append(positions, float64(i)*float64(physconst.OutputMeshSpacing[spec.free[0]]))
+		values = append(values, v[0])
+	}
+	return axisName[spec.free[0]], positions, values, true
+}
+
+// 1次元データをSVG用の系列に変換します。
+func lineSeries(label string, positions []float64, values []float32) svgplot.Series {
+	series := svgplot.Series{Label: label, X: positions, Y: make([]float64, len(values))}
+	for i, v := range values {
+		series.Y[i] = float64(v)
+	}
+	return series
+}
+
+// テキスト出力をスクリプト生成用に登録します。patternは出力ディレクトリからの相対パスです。
+func registerScript(name string, pattern string, mode string, quantity string, unit string, size [3]int, fileID int) {
+	value := plotscript.Column{Name: quantity, Unit: unit}
+	grid := func(axis string) plotscript.Column { return plotscript.Column{Name: axis, Unit: "grid"} }
+	kind := plotscript.Kind{Name: name, Pattern: pattern, Slice: mode}
+	if isAverageMode(mode) {
+		spec, err := parseAverageSpec(mode, size)
+		if err != nil || len(spec.keep) == 0 {
+			return
+		}
+		kind.Format = plotscript.Line
+		if len(spec.keep) == 2 {
+			kind.Format = plotscript.Map
+		}
+		for _, axis := range spec.keep {
+			kind.Columns = append(kind.Columns, grid(axisName[axis]))
+		}
+		for _, band := range spec.columnNames() {
+			kind.Columns = append(kind.Columns, plotscript.Column{Name: fmt.Sprintf("%s %s", quantity, band), Unit: unit})
+		}
+		kind.Values = len(spec.columnNames())
+		plotscript.Register(kind, fileID)
+		return
+	}
+	base, _, _ := strings.Cut(mode, "@")
+	switch base {
+	case "xy", "yz", "zx":
+		kind.Format = plotscript.Map
+		kind.Columns = []plotscript.Column{grid(base[:1]), grid(base[1:2]), value}
+	case "x", "y", "z":
+		kind.Format = plotscript.Line
+		kind.Columns = []plotscript.Column{grid(base), value}
+	case "line":
+		kind.Format = plotscript.Line
+		kind.Columns = []plotscript.Column{{Name: "s", Unit: "µm"}, value, grid("x"), grid("y"), grid("z")}
+	default:
+		return
+	}
+	plotscript.Register(kind, fileID)
+}
+
+// npy, pngが指定されていれば、切り出したデータをNumPy形式とPNG画像で書き出します。
+// xyzはnpyのみ、断面はnpyとpng、線はnpyのみ対応しています。
+func writeSliceImages(g [][][]float32, quantity string, mode string, center string, basename string, options render.Options, plotConfig plotconfig.Art, wg *sync.WaitGroup) {
+	writeNPY, writePNG := plotconfig.HasFlag(center, "npy"), plotconfig.HasFlag(center, "png")
+	if !writeNPY && !writePNG {
+		return
+	}
+	if mode == "xyz" {
+		if writeNPY {
+			wg.Add(1)
+			go npy.WriteArray3D(g, fmt.Sprintf("%s/%s.npy", plotConfig.OutputNPYDirectory, basename), wg)
+		}
+		return
+	}
+	if _, _, values, ok := cut1D(g, mode); ok {
+		if writeNPY {
+			wg.Add(1)
+			go npy.WriteArray1D(values, fmt.Sprintf("%s/%s.npy", plotConfig.OutputNPYDirectory, basename), wg)
+		}
+		return
+	}
+	spec, err := parseSliceSpec(mode, [3]int{len(g), len(g[0]), len(g[0][0])})
+	if err != nil {
+		return
+	}
+	plane := spec.extract(g)
+	if writeNPY {
+		wg.Add(1)
+		go npy.WriteArray2D(plane, fmt.Sprintf("%s/%s.npy", plotConfig.OutputNPYDirectory, basename), wg)
+	}
+	if writePNG {
+		wg.Add(1)
+		go render.WriteHeatmap(plane, options, fmt.Sprintf("%s/%s.png", plotConfig.OutputPNGDirectory, basename), wg)
+	}
+}
+
+// 1次元データのグラフの雛形を作ります。
+func lineChart(title string, axis string, quantity string, unit string, center string) svgplot.Chart {
+	return svgplot.Chart{
+		Title: title,
+		XAxis: svgplot.Axis{Label: axis, Unit: "µm", Log: plotconfig.HasFlag(center, "logx")},
+		YAxis: svgplot.Axis{Label: quantity, Unit: unit, Log: plotconfig.HasFlag(center, "logy")},
+	}
+}
+
 func WriteFieldData(g [][][]float32, mode string, fname string, wg *sync.WaitGroup) {
 	fout, err := os.Create(fname)
 	defer fout.Close()
@@ -41,94 +185,62 @@ func WriteFieldData(g [][][]float32, mode string, fname string, wg *sync.WaitGro
 			writer.WriteString(fmt.Sprintln(""))
 		}
 		break
-	case "xy":
-		for x := 0; x < xsize; x++ {
-			for y := 0; y < ysize; y++ {
-				writer.WriteString(fmt.Sprintln(x, y, g[x][y][zsize/2]))
+	default:
+		if isAverageMode(mode) {
+			spec, err := parseAverageSpec(mode, [3]int{xsize, ysize, zsize})
+			if err != nil {
+				fmt.Println("Warning:invalid mode:", mode, err)
+				break
 			}
-			writer.WriteString(fmt.Sprintln(""))
+			writeAverage(writer, g, mode, spec)
+			break
 		}
-		break
-	case "yz":
-		for y := 0; y < ysize; y++ {
-			for z := 0; z < zsize; z++ {
-				writer.WriteString(fmt.Sprintln(y, z, g[xsize/2][y][z]))
+		if strings.HasPrefix(mode, "line@") {
+			l, err := parseLineout(mode, [3]int{xsize, ysize, zsize})
+			if err != nil {
+				fmt.Println("Warning:invalid mode:", mode, err)
+				break
 			}
-			writer.WriteString(fmt.Sprintln(""))
-		}
-		break
-	case "zx":
-		for z := 0; z < zsize; z++ {
-			for x := 0; x < xsize; x++ {
-				writer.WriteString(fmt.Sprintln(z, x, g[x][ysize/2][z]))
+			writer.WriteString(fmt.Sprintf("# s(um) value x y z : %s\n", mode))
+			distance, coordinates, values := l.sample(g)
+			for i, v := range values {
+				writer.WriteString(fmt.Sprintln(distance[i], v, coordinates[i][0], coordinates[i][1], coordinates[i][2]))
 			}
-			writer.WriteString(fmt.Sprintln(""))
+			break
 		}
-		break
-	case "x":
-		for x := 0; x < xsize; x++ {
-			writer.WriteString(fmt.Sprintln(x, g[x][ysize/2][zsize/2]))
-		}
-		break
-	case "y":
-		for y := 0; y < ysize; y++ {
-			writer.WriteString(fmt.Sprintln(y, g[xsize/2][y][zsize/2]))
-		}
-		break
-	case "z":
-		for z := 0; z < zsize; z++ {
-			writer.WriteString(fmt.Sprintln(z, g[xsize/2][ysize/2][z]))
+		spec, err := parseSliceSpec(mode, [3]int{xsize, ysize, zsize})
+		if err != nil {
+			fmt.Println("Warning:invalid mode:", mode, err)
+			break
 		}
-		break
-	case "zxaverage":
-		for z := 0; z < zsize; z++ {
-			for x := 0; x < zsize; x++ {
-				sum := float32(0)
-				for y := 0; y < ysize; y++ {
-					sum += g[x][y][z]
-				}
-				sum /= float32(ysize)
-				writer.WriteString(fmt.Sprintln(z, x, sum))
+		if strings.Contains(mode, "@") {
+			columns := []string{}
+			for _, axis := range spec.free {
+				columns = append(columns, axisName[axis])
 			}
-			writer.WriteString("\n")
-		}
-		break
-	case "xaverage":
-		average := make([][]float32, xsize)
-		for x := 0; x < xsize; x++ {
-			average[x] = make([]float32, ysize)
+			writer.WriteString(fmt.Sprintf("# %s value : %s\n", strings.Join(columns, " "), spec.describe()))
 		}
-		for x := 0; x < xsize; x++ {
-			for y := 1; y < ysize; y++ {
-				average[x][y] += average[x][y-1] + g[x][y][zsize/2]
+		for i, row := range spec.extract(g) {
+			if len(spec.free) == 1 {
+				writer.WriteString(fmt.Sprintln(i, row[0]))
+				continue
 			}
-			var averagieze func(int) float32 = func(n int) float32 {
-				return (average[x][n*ysize/8] - average[x][(n-1)*ysize/8]) / (float32(ysize) / 8)
+			for j, v := range row {
+				writer.WriteString(fmt.Sprintln(i, j, v))
 			}
-			writer.WriteString(fmt.Sprintln(x, averagieze(1), averagieze(2), averagieze(3), averagieze(4),
-				averagieze(5), averagieze(6), averagieze(7)))
+			writer.WriteString(fmt.Sprintln(""))
 		}
-		break
-	case "whole_average":
-    sum := float32(0)
-    for x := 0; x < xsize; x++{
-      for y:= 0; y < ysize; y++ {
-        for z:=0; z < zsize; z++ {
-          sum += g[x][y][z]
-        }
-      }
-    }
-    average := float32(sum) / float32(xsize * ysize * zsize)
-    writer.WriteString(fmt.Sprintln(average))
-    break
-	default:
-		fmt.Println("Warning:invalid mode:", mode)
 	}
 	writer.Flush()
 	fout.Close()
 	wg.Done()
 }
 func WriteFieldVTK(g [][][]float32, fname string, arrayName string, config simulationconfig.SimulationConfig, wg *sync.WaitGroup) {
+	writeVTK(g, fname, arrayName, config, nil, wg)
+}
+
+// fieldDataを<FieldData>に書き出すWriteFieldVTKです。
+func writeVTK(g [][][]float32, fname string, arrayName string, config simulationconfig.SimulationConfig, fieldData map[string]float32, wg *sync.WaitGroup) {
 	fout, err := os.Create(fname)
 	defer fout.Close()
 	if err != nil {
@@ -138,6 +250,18 @@ func WriteFieldVTK(g [][][]float32, fname string, arrayName string, config simul
 	writer.WriteString("<?xml version=\"1.0\"?>\n")
 	writer.WriteString("<VTKFile type=\"ImageData\" byte_order=\"LittleEndian\">")
 	writer.WriteString(fmt.Sprintf("<ImageData WholeExtent=\"0 %d 0 %d 0 %d\" Origin=\"0 0 0\" Spacing=\"1.0 1.0 1.0\">", config.OutputMeshNumber[0]-1, config.OutputMeshNumber[1]-1, config.OutputMeshNumber[2]-1))
+	if len(fieldData) > 0 {
+		names := []string{}
+		for name := range fieldData {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		writer.WriteString("<FieldData>")
+		for _, name := range names {
+			writer.WriteString(fmt.Sprintf("<DataArray type=\"Float32\" Name=\"%s\" NumberOfTuples=\"1\" format=\"ascii\">%g</DataArray>", name, fieldData[name]))
+		}
+		writer.WriteString("</FieldData>")
+	}
 	writer.WriteString(fmt.Sprintf("<Piece Extent=\"0 %d 0 %d 0 %d\">", config.OutputMeshNumber[0]-1, config.OutputMeshNumber[1]-1, config.OutputMeshNumber[2]-1))
 	writer.WriteString(fmt.Sprintf("<PointData Scalars=\"%s\">", arrayName))
 	writer.WriteString(fmt.Sprintf("<DataArray Name=\"%s\" type=\"Float32\" format=\"binary\">", arrayName))
@@ -168,83 +292,198 @@ func WriteFieldVTK(g [][][]float32, fname string, arrayName string, config simul
 	fout.Close()
 	wg.Done()
 }
-func LoadWriteFieldData(file *os.File, config simulationconfig.SimulationConfig, plotConfig plotconfig.Art, fileID int, wg *sync.WaitGroup) {
+
+// プロセスごとのブロックを連結したメッシュデータを全体の順に並べ替えます。
+// メッシュデータはx, z, yの順(xが最も速い)に並んでいます。並べ替えられなければ警告し、そのまま返します。
+func reorderMesh(g []float32, config simulationconfig.SimulationConfig) []float32 {
+	res, err := decomposition.Reorder(g, []int{int(config.OutputMeshNumber[0]), int(config.OutputMeshNumber[2]), int(config.OutputMeshNumber[1])}, []int{0, 2, 1})
+	if err != nil {
+		fmt.Printf("\x1b[35mwarning : メッシュデータを並べ替えられませんでした : %s\x1b[0m\n", err)
+	}
+	return res
+}
+
+// 1ステップ分の3次元データ。キーは物理量の名前です。
+type FieldSet map[string][][][]float32
+
+// 粒子種ごとのメッシュデータ。キーは粒子種の番号(1始まり)で、物理量の名前はDensity, Energyなどです。
+type ParticleMeshSet map[int32]FieldSet
+
+// 出力ファイルと同じ名前(Ion_Density_is=01, C_Density_is=01など)で粒子のメッシュデータを探します。
+// 名前は粒子種の名前(SpeciesName)かIon, Electronのどちらか、物理量、_is=と粒子種の番号を並べたもので、
+// 番号の粒子種の名前と一致しなければ見つかりません。Temperatureは出力しないステップでもここで求めます。
+func (particles ParticleMeshSet) Lookup(name string, config simulationconfig.SimulationConfig, plotConfig plotconfig.Art) ([][][]float32, bool) {
+	qualified, number, found := strings.Cut(name, "_is=")
+	if !found {
+		return nil, false
+	}
+	id, err := strconv.Atoi(number)
+	if err != nil || id < 1 || id > int(config.TotalParticleSpecies) || fmt.Sprintf("%02d", id) != number {
+		return nil, false
+	}
+	species := int32(id)
+	mesh := particles[species]
+	for _, prefix := range []string{plotconfig.SpeciesName(plotConfig, config, species), plotconfig.SpeciesKind(config, species)} {
+		if !strings.HasPrefix(qualified, prefix+"_") {
+			continue
+		}
+		quantity := strings.TrimPrefix(qualified, prefix+"_")
+		if g, found := mesh[quantity]; found {
+			return g, true
+		}
+		if quantity == "Temperature" && mesh["Density"] != nil {
+			return temperature(mesh), true
+		}
+	}
+	return nil, false
+}
+
+// 1つの物理量をplot.jsonのFieldの指定に従って書き出します。
+func writeFieldQuantity(buf [][][]float32, v string, config simulationconfig.SimulationConfig, plotConfig plotconfig.Art, fileID int, wg *sync.WaitGroup) {
+	writeQuantity(buf, v, plotConfig.Field, config, plotConfig, fileID, wg)
+}
+
+// 1つの物理量をsubartsの指定に従って書き出します。
+func writeQuantity(buf [][][]float32, v string, subarts []plotconfig.Subart, config simulationconfig.SimulationConfig, plotConfig plotconfig.Art, fileID int, wg *sync.WaitGroup) {
+	for _, vconfig := range subarts {
+		if vconfig.Name == v {
+			if !vconfig.Plot {
+				break
+			}
+			buf, unit, critical := inDensityUnit(buf, v, plotConfig)
+			contours, fieldData := criticalDensityMarks(critical)
+			for _, vcenter := range plotconfig.Modes(vconfig.Center) {
+				wg.Add(1)
+				if vcenter == "vtk" {
+					go writeVTK(buf, fmt.Sprintf("%s/%s%04d.vti", plotConfig.OutputVTKDirectory, v, fileID), v, config, fieldData, wg)
+				} else {
+					go WriteFieldData(buf, vcenter, fmt.Sprintf("%s/%s_%s_%04d.txt", plotConfig.OutputASCIIDirectory, v, fileTag(vcenter), fileID), wg)
+					registerScript(fmt.Sprintf("%s_%s", v, fileTag(vcenter)), fmt.Sprintf("%s_%s_%%04d.txt", v, fileTag(vcenter)), vcenter, v, unit, [3]int{len(buf), len(buf[0]), len(buf[0][0])}, fileID)
+				}
+				writeSliceImages(buf, v, vcenter, vconfig.Center, fmt.Sprintf("%s_%s_%04d", v, fileTag(vcenter), fileID), render.Options{Contours: contours}, plotConfig, wg)
+				if axis, positions, values, ok := cut1D(buf, vcenter); ok && plotconfig.HasFlag(vconfig.Center, "svg") {
+					chart := lineChart(fmt.Sprintf("%s %s %04d", v, vcenter, fileID), axis, v, unit, vconfig.Center)
+					chart.Series = append(chart.Series, lineSeries(v, positions, values))
+					wg.Add(1)
+					go svgplot.WriteLinePlot(chart, fmt.Sprintf("%s/%s_%s_%04d.svg", plotConfig.OutputSVGDirectory, v, fileTag(vcenter), fileID), wg)
+				}
+			}
+		}
+	}
+}
+
+func LoadWriteFieldData(file *os.File, config simulationconfig.SimulationConfig, plotConfig plotconfig.Art, fileID int, wg *sync.WaitGroup) FieldSet {
 	title := [...]string{"Ex", "Ey", "Ez", "Bx", "By", "Bz", "Jx", "Jy", "Jz"}
 	normalizeConst := [...]float32{physconst.ElectricFieldNormalizeConstant, physconst.ElectricFieldNormalizeConstant, physconst.ElectricFieldNormalizeConstant,
 		physconst.MagneticFieldNormalizeConstant, physconst.MagneticFieldNormalizeConstant, physconst.MagneticFieldNormalizeConstant,
 		1.0, 1.0, 1.0}
+	fields := FieldSet{}
 	for i, v := range title {
 		fmt.Printf("\r\033[K loading... %s", v)
 		g := []float32{}
 		g = make([]float32, config.TotalOutputMeshNumber)
 		nextchunk := fortbin.ReadNextChunk(file)
 		binary.Read(nextchunk, binary.LittleEndian, &g)
+		g = reorderMesh(g, config)
 		buf := utility.Slice1Dto3D(g, config.OutputMeshNumber[0], config.OutputMeshNumber[1], config.OutputMeshNumber[2], normalizeConst[i])
+		fields[v] = buf
+		writeFieldQuantity(buf, v, config, plotConfig, fileID, wg)
+	}
 
-		for _, vconfig := range plotConfig.Field {
-			if vconfig.Name == v {
-				if !vconfig.Plot {
-					break
-				}
-				for _, vcenter := range strings.Split(vconfig.Center, " ") {
-					wg.Add(1)
-					if vcenter == "vtk" {
-						go WriteFieldVTK(buf, fmt.Sprintf("%s/%s%04d.vti", plotConfig.OutputVTKDirectory, v, fileID), v, config, wg)
-					} else {
-						go WriteFieldData(buf, vcenter, fmt.Sprintf("%s/%s_%s_%04d.txt", plotConfig.OutputASCIIDirectory, v, vcenter, fileID), wg)
-					}
-				}
-			}
+	// 派生量は出力する場合のみ計算する
+	for _, v := range DerivedFieldNames {
+		if subart, found := plotconfig.FindSubart(plotConfig.Field, v); found && subart.Plot {
+			fmt.Printf("\r\033[K calculating... %s", v)
+			buf, _ := fields.Derived(v)
+			writeFieldQuantity(buf, v, config, plotConfig, fileID, wg)
 		}
 	}
+	grid := fieldop.NewGrid(config, plotConfig.FieldBoundary, plotConfig.FieldStaggering)
+	for _, v := range OperatorFieldNames {
+		if subart, found := plotconfig.FindSubart(plotConfig.Field, v); found && subart.Plot {
+			fmt.Printf("\r\033[K calculating... %s", v)
+			buf, _ := fields.Operator(v, grid)
+			writeFieldQuantity(buf, v, config, plotConfig, fileID, wg)
+		}
+	}
+	return fields
 }
-func LoadWriteParticleMeshData(file *os.File, config simulationconfig.SimulationConfig, plotConfig plotconfig.Art, fileID int, wg *sync.WaitGroup) {
+func LoadWriteParticleMeshData(file *os.File, config simulationconfig.SimulationConfig, plotConfig plotconfig.Art, fileID int, wg *sync.WaitGroup) ParticleMeshSet {
+	particles := ParticleMeshSet{}
 
-	title_particle := [...]string{"Ion_Density", "Ion_Energy", "Ion_EnergyFlux_x", "Ion_EnergyFlux_y"}
-	title_particle_Electron := [...]string{"Electron_Density", "Electron_Energy", "Electron_EnergyFlux_x", "Electron_EnergyFlux_y"}
+	title_particle := [...]string{"Density", "Energy", "EnergyFlux_x", "EnergyFlux_y"}
 
-	for ionID := int32(1); ionID <= config.IonNumber; ionID++ {
-		for _, v := range title_particle {
-			fmt.Printf("\r\033[K loading... %s", v)
+	// 全粒子種を1枚に重ねたSVGのグラフ。キーは"物理量_モード"
+	overlay := map[string]*svgplot.Chart{}
+	addOverlay := func(quantity string, unit string, vconfig plotconfig.Subart, mode string, label string, buf [][][]float32) {
+		axis, positions, values, ok := cut1D(buf, mode)
+		if !ok || !plotconfig.HasFlag(vconfig.Center, "svg") {
+			return
+		}
+		key := quantity + "_" + fileTag(mode)
+		if _, exists := overlay[key]; !exists {
+			chart := lineChart(fmt.Sprintf("%s %s %04d", quantity, mode, fileID), axis, quantity, unit, vconfig.Center)
+			overlay[key] = &chart
+		}
+		overlay[key].Series = append(overlay[key].Series, lineSeries(label, positions, values))
+	}
+
+	for species := int32(1); species <= config.TotalParticleSpecies; species++ {
+		name := plotconfig.SpeciesName(plotConfig, config, species)
+		subarts := plotconfig.ParticleSubarts(plotConfig, config, species)
+		particles[species] = FieldSet{}
+		for _, quantity := range title_particle {
+			fmt.Printf("\r\033[K loading... %s_%s", name, quantity)
 			g := []float32{}
 			g = make([]float32, config.TotalOutputMeshNumber)
 			binary.Read(fortbin.ReadNextChunk(file), binary.LittleEndian, &g)
-			buf := utility.Slice1Dto3D(g, config.OutputMeshNumber[0], config.OutputMeshNumber[1], config.OutputMeshNumber[2], 1.0)
+			g = reorderMesh(g, config)
+			particles[species][quantity] = utility.Slice1Dto3D(g, config.OutputMeshNumber[0], config.OutputMeshNumber[1], config.OutputMeshNumber[2], 1.0)
+		}
+		// Temperatureは出力するときだけ求める。プローブなどではLookupがその都度求める
+		for _, vconfig := range subarts {
+			if vconfig.Name == "Temperature" && vconfig.Plot {
+				particles[species]["Temperature"] = temperature(particles[species])
+				break
+			}
+		}
 
-			for _, vconfig := range plotConfig.Particle {
-				if vconfig.Name == v && vconfig.Plot {
-					for _, vplot := range strings.Split(vconfig.Center, " ") {
+		for _, quantity := range append(title_particle[:], "Temperature") {
+			if particles[species][quantity] == nil {
+				continue
+			}
+			v := name + "_" + quantity
+			buf, unit, critical := inDensityUnit(particles[species][quantity], quantity, plotConfig)
+			contours, fieldData := criticalDensityMarks(critical)
+			for _, vconfig := range subarts {
+				if vconfig.Name == quantity && vconfig.Plot {
+					for _, vplot := range plotconfig.Modes(vconfig.Center) {
 						wg.Add(1)
 						if vplot == "vtk" {
-							go WriteFieldVTK(buf, fmt.Sprintf("%s/%s%04d_is=%02d.vti", plotConfig.OutputVTKDirectory, v, fileID, ionID), v, config, wg)
+							go writeVTK(buf, fmt.Sprintf("%s/%s%04d_is=%02d.vti", plotConfig.OutputVTKDirectory, v, fileID, species), v, config, fieldData, wg)
 						} else {
-							go WriteFieldData(buf, vplot, fmt.Sprintf("%s/%s_%s_%04d_is=%02d.txt", plotConfig.OutputASCIIDirectory, v, vplot, fileID, ionID), wg)
+							go WriteFieldData(buf, vplot, fmt.Sprintf("%s/%s_%s_%04d_is=%02d.txt", plotConfig.OutputASCIIDirectory, v, fileTag(vplot), fileID, species), wg)
+							registerScript(fmt.Sprintf("%s_%s_is=%02d", v, fileTag(vplot), species), fmt.Sprintf("%s_%s_%%04d_is=%02d.txt", v, fileTag(vplot), species), vplot, v, unit, [3]int{len(buf), len(buf[0]), len(buf[0][0])}, fileID)
 						}
+						writeSliceImages(buf, v, vplot, vconfig.Center, fmt.Sprintf("%s_%s_%04d_is=%02d", v, fileTag(vplot), fileID, species), render.Options{Contours: contours}, plotConfig, wg)
+						addOverlay(quantity, unit, vconfig, vplot, fmt.Sprintf("%s is=%02d", name, species), buf)
 					}
 				}
 			}
 		}
 	}
 
-	for ElectronID := config.IonNumber + 1; ElectronID <= config.TotalParticleSpecies; ElectronID++ {
-		for _, v := range title_particle_Electron {
-			g := []float32{}
-			g = make([]float32, config.TotalOutputMeshNumber)
-			fmt.Printf("\r\033[K loading... %s", v)
-			binary.Read(fortbin.ReadNextChunk(file), binary.LittleEndian, &g)
-			buf := utility.Slice1Dto3D(g, config.OutputMeshNumber[0], config.OutputMeshNumber[1], config.OutputMeshNumber[2], 1.0)
-			for _, vconfig := range plotConfig.Particle {
-				if vconfig.Name == v && vconfig.Plot {
-					for _, vplot := range strings.Split(vconfig.Center, " ") {
-						wg.Add(1)
-						if vplot == "vtk" {
-							go WriteFieldVTK(buf, fmt.Sprintf("%s/%s%04d_is=%02d.vti", plotConfig.OutputVTKDirectory, v, fileID, ElectronID), v, config, wg)
-						} else {
-							go WriteFieldData(buf, vplot, fmt.Sprintf("%s/%s_%s_%04d_is=%02d.txt", plotConfig.OutputASCIIDirectory, v, vplot, fileID, ElectronID), wg)
-						}
-					}
-				}
+	for _, v := range ParticleDerivedNames {
+		if subart, found := plotconfig.FindSubart(plotConfig.Particle, v); found && subart.Plot {
+			if buf, found := particles.Derived(v, config); found {
+				writeQuantity(buf, v, plotConfig.Particle, config, plotConfig, fileID, wg)
 			}
 		}
 	}
+
+	for key, chart := range overlay {
+		wg.Add(1)
+		go svgplot.WriteLinePlot(*chart, fmt.Sprintf("%s/%s_%04d.svg", plotConfig.OutputSVGDirectory, key, fileID), wg)
+	}
+	return particles
 }
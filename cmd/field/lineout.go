@@ -0,0 +1,116 @@
+package field
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/Penpen7/goplot/cmd/physconst"
+	"github.com/Penpen7/goplot/cmd/utility"
+)
+
+// 任意の直線・折れ線に沿ったラインアウト
+type lineout struct {
+	points  [][3]float64
+	samples int
+}
+
+// ラインアウトの指定を解釈します。
+// 頂点を:で区切って2点以上並べ、最後にn=で標本点の数を指定できます。
+// 座標はparseCoordinateと同じ書式です。
+//
+//	line@0,mid,mid:63,mid,mid
+//	line@0um,2um,mid:10um,6um,mid:n=200
+//	line@0,0,mid:32,16,mid:63,0,mid
+func parseLineout(mode string, size [3]int) (lineout, error) {
+	spec, found := strings.CutPrefix(mode, "line@")
+	if !found {
+		return lineout{}, fmt.Errorf("invalid mode: %s", mode)
+	}
+	var l lineout
+	for _, v := range strings.Split(spec, ":") {
+		if n, isSamples := strings.CutPrefix(v, "n="); isSamples {
+			samples, err := strconv.Atoi(n)
+			if err != nil || samples < 2 {
+				return lineout{}, fmt.Errorf("invalid sample number: %s", v)
+			}
+			l.samples = samples
+			continue
+		}
+		point, err := ParsePoint(v, size)
+		if err != nil {
+			return lineout{}, err
+		}
+		l.points = append(l.points, point)
+	}
+	if len(l.points) < 2 {
+		return lineout{}, fmt.Errorf("ラインアウトには2点以上必要です: %s", mode)
+	}
+	if l.samples == 0 {
+		length := 0.0
+		for i := 1; i < len(l.points); i++ {
+			length += l.segmentLength(i, false)
+		}
+		l.samples = int(math.Ceil(length)) + 1
+	}
+	return l, nil
+}
+
+// "x,y,z"の形式の点を格子上の座標に変換します。座標はparseCoordinateと同じ書式です。
+func ParsePoint(s string, size [3]int) ([3]float64, error) {
+	var point [3]float64
+	coordinates := strings.Split(s, ",")
+	if len(coordinates) != 3 {
+		return point, fmt.Errorf("invalid point: %s", s)
+	}
+	for axis, c := range coordinates {
+		var err error
+		if point[axis], err = parseCoordinate(c, axis, size[axis]); err != nil {
+			return point, err
+		}
+	}
+	return point, nil
+}
+
+// i-1番目からi番目の頂点までの長さ。physicalがtrueならµm、falseなら格子単位です。
+func (l lineout) segmentLength(i int, physical bool) float64 {
+	sum := 0.0
+	for axis := 0; axis < 3; axis++ {
+		d := l.points[i][axis] - l.points[i-1][axis]
+		if physical && physconst.OutputMeshSpacing[axis] != 0 {
+			d *= float64(physconst.OutputMeshSpacing[axis])
+		}
+		sum += d * d
+	}
+	return math.Sqrt(sum)
+}
+
+// 折れ線に沿って等間隔に標本点を取り、三線形補間した値を返します。
+// distanceは始点からの距離(µm)、coordinatesは標本点の格子上の座標です。
+func (l lineout) sample(g [][][]float32) (distance []float64, coordinates [][3]float64, values []float32) {
+	cumulative := []float64{0}
+	for i := 1; i < len(l.points); i++ {
+		cumulative = append(cumulative, cumulative[i-1]+l.segmentLength(i, true))
+	}
+	total := cumulative[len(cumulative)-1]
+	segment := 1
+	for n := 0; n < l.samples; n++ {
+		s := total * float64(n) / float64(l.samples-1)
+		for segment < len(l.points)-1 && s > cumulative[segment] {
+			segment++
+		}
+		t := 0.0
+		if length := cumulative[segment] - cumulative[segment-1]; length > 0 {
+			t = (s - cumulative[segment-1]) / length
+		}
+		var p [3]float64
+		for axis := 0; axis < 3; axis++ {
+			p[axis] = l.points[segment-1][axis] + t*(l.points[segment][axis]-l.points[segment-1][axis])
+		}
+		distance = append(distance, s)
+		coordinates = append(coordinates, p)
+		values = append(values, utility.Trilinear(g, p[0], p[1], p[2]))
+	}
+	return distance, coordinates, values
+}
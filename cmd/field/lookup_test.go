@@ -0,0 +1,42 @@
+package field
+
+import (
+	"testing"
+
+	"github.com/Penpen7/goplot/cmd/plotconfig"
+	"github.com/Penpen7/goplot/cmd/simulationconfig"
+)
+
+func TestParticleMeshSetLookup(t *testing.T) {
+	config := simulationconfig.SimulationConfig{TotalParticleSpecies: 2, IonNumber: 1,
+		Particle: []simulationconfig.SimulationParticleConfig{{Atom: "C"}, {}}}
+	ion := [][][]float32{{{1}}}
+	electron := [][][]float32{{{2}}}
+	particles := ParticleMeshSet{1: FieldSet{"Density": ion}, 2: FieldSet{"Density": electron}}
+	tests := []struct {
+		name  string
+		want  [][][]float32
+		found bool
+	}{
+		{"C_Density_is=01", ion, true},
+		{"Ion_Density_is=01", ion, true},
+		{"Electron_Density_is=02", electron, true},
+		// 番号の粒子種と名前が一致しない
+		{"Ion_Density_is=02", nil, false},
+		{"Electron_Density_is=01", nil, false},
+		{"C_Density_is=02", nil, false},
+		// 物理量の名前の前に余計な文字がある
+		{"XC_Density_is=01", nil, false},
+		{"Ion_IonDensity_is=01", nil, false},
+		{"Density_is=01", nil, false},
+		// 存在しない粒子種
+		{"Electron_Density_is=03", nil, false},
+		{"Ion_Density_is=1", nil, false},
+	}
+	for _, tt := range tests {
+		g, found := particles.Lookup(tt.name, config, plotconfig.Art{})
+		if found != tt.found || (found && &g[0][0][0] != &tt.want[0][0][0]) {
+			t.Errorf("Lookup(%q) = %v, %v, want %v, %v", tt.name, g, found, tt.want, tt.found)
+		}
+	}
+}
@@ -0,0 +1,178 @@
+package field
+
+import (
+	"math"
+	"sync"
+
+	"github.com/Penpen7/goplot/cmd/fieldop"
+	"github.com/Penpen7/goplot/cmd/physconst"
+	"github.com/Penpen7/goplot/cmd/plotconfig"
+	"github.com/Penpen7/goplot/cmd/plotscript"
+	"github.com/Penpen7/goplot/cmd/runtable"
+	"github.com/Penpen7/goplot/cmd/simulationconfig"
+)
+
+// 差分演算で求める量。規格化単位で計算します。
+var OperatorFieldNames = [...]string{"divE", "divB", "curlEx", "curlEy", "curlEz", "curlBx", "curlBy", "curlBz", "CurlBminusJx", "CurlBminusJy", "CurlBminusJz"}
+
+// 粒子のメッシュデータから差分演算で求める量
+var GaussLawFieldNames = [...]string{"GaussResidual", "gradRhox", "gradRhoy", "gradRhoz"}
+
+// E, B, Jを規格化単位に戻したベクトルを返します。
+func (fields FieldSet) codeVector(name string) [3][][][]float32 {
+	normalizeConstant := float32(1.0)
+	switch name {
+	case "E":
+		normalizeConstant = physconst.ElectricFieldNormalizeConstant
+	case "B":
+		normalizeConstant = physconst.MagneticFieldNormalizeConstant
+	}
+	var v [3][][][]float32
+	for axis, component := range axisName {
+		g := fields[name+component]
+		v[axis] = newArrayLike(g)
+		for x := range g {
+			for y := range g[x] {
+				for z := range g[x][y] {
+					v[axis][x][y][z] = g[x][y][z] / normalizeConstant
+				}
+			}
+		}
+	}
+	return v
+}
+
+func newArrayLike(g [][][]float32) [][][]float32 {
+	res := make([][][]float32, len(g))
+	for x := range g {
+		res[x] = make([][]float32, len(g[x]))
+		for y := range g[x] {
+			res[x][y] = make([]float32, len(g[x][y]))
+		}
+	}
+	return res
+}
+
+// 差分演算で求める量を返します。
+func (fields FieldSet) Operator(name string, grid fieldop.Grid) ([][][]float32, bool) {
+	switch name {
+	case "divE":
+		return fieldop.DivergenceE(fields.codeVector("E"), grid), true
+	case "divB":
+		return fieldop.DivergenceB(fields.codeVector("B"), grid), true
+	case "curlEx", "curlEy", "curlEz":
+		return fieldop.CurlE(fields.codeVector("E"), grid)[name[4]-'x'], true
+	case "curlBx", "curlBy", "curlBz":
+		return fieldop.CurlB(fields.codeVector("B"), grid)[name[4]-'x'], true
+	case "CurlBminusJx", "CurlBminusJy", "CurlBminusJz":
+		axis := name[11] - 'x'
+		res := fieldop.CurlB(fields.codeVector("B"), grid)[axis]
+		j := fields.codeVector("J")[axis]
+		for x := range res {
+			for y := range res[x] {
+				for z := range res[x][y] {
+					res[x][y][z] -= j[x][y][z]
+				}
+			}
+		}
+		return res, true
+	}
+	return nil, false
+}
+
+// 電荷密度 Σ q_s n_s を規格化単位で求めます。
+// q_sは素電荷を単位としたParticleCharge、n_sは_Densityメッシュの値で、
+// 密度の規格化定数n0(physconst.NormalizedNumberDensity)を単位とします。
+func ChargeDensity(particles ParticleMeshSet, config simulationconfig.SimulationConfig) [][][]float32 {
+	var rho [][][]float32
+	for species := int32(1); species <= config.TotalParticleSpecies; species++ {
+		density, found := particles[species]["Density"]
+		if !found {
+			continue
+		}
+		if rho == nil {
+			rho = newArrayLike(density)
+		}
+		charge := float32(config.Particle[species-1].ParticleCharge)
+		for x := range density {
+			for y := range density[x] {
+				for z := range density[x][y] {
+					rho[x][y][z] += charge * density[x][y][z]
+				}
+			}
+		}
+	}
+	return rho
+}
+
+// 配列のL2ノルムと絶対値の最大値を返します。
+func norms(g [][][]float32) (float64, float64) {
+	sum, max := 0.0, 0.0
+	for x := range g {
+		for y := range g[x] {
+			for z := range g[x][y] {
+				v := float64(g[x][y][z])
+				sum += v * v
+				max = math.Max(max, math.Abs(v))
+			}
+		}
+	}
+	return math.Sqrt(sum), max
+}
+
+// ガウスの法則 ∇・E = ρ と ∇・B = 0 の残差を求め、GaussLaw.txtに1行追記します。
+// 規格化単位では4πなどの係数はつきません。電場の規格化定数は4π n0 e Δx(physconstの
+// ElectricFieldNormalizeConstant)で、長さをΔx単位にとるとガウス単位系の ∇・E = 4π e Σ q_s n_s が
+// ∇・E = Σ q_s (n_s/n0) になるためです。密度がn0単位であることを前提にしています。
+// plot.jsonで指定されていれば、残差 ∇・E - ρ と ∇ρ も出力します。
+// どれも指定されていなければ何も計算せず、指定された出力に必要な差分演算だけを行います。
+func WriteGaussLaw(fields FieldSet, particles ParticleMeshSet, config simulationconfig.SimulationConfig, plotConfig plotconfig.Art, fileID int, simulationTime float32, wg *sync.WaitGroup) {
+	enabled := func(name string) bool {
+		subart, found := plotconfig.FindSubart(plotConfig.Field, name)
+		return found && subart.Plot
+	}
+	table := enabled("GaussLaw")
+	needResidual := table || enabled("GaussResidual")
+	needGradient := enabled("gradRhox") || enabled("gradRhoy") || enabled("gradRhoz")
+	if (!needResidual && !needGradient) || fields["Ex"] == nil {
+		return
+	}
+	rho := ChargeDensity(particles, config)
+	if rho == nil {
+		return
+	}
+	grid := fieldop.NewGrid(config, plotConfig.FieldBoundary, plotConfig.FieldStaggering)
+	if needGradient {
+		gradient := fieldop.Gradient(rho, grid)
+		for axis, v := range GaussLawFieldNames[1:] {
+			writeFieldQuantity(gradient[axis], v, config, plotConfig, fileID, wg)
+		}
+	}
+	if !needResidual {
+		return
+	}
+	residual := fieldop.DivergenceE(fields.codeVector("E"), grid)
+	for x := range residual {
+		for y := range residual[x] {
+			for z := range residual[x][y] {
+				residual[x][y][z] -= rho[x][y][z]
+			}
+		}
+	}
+	writeFieldQuantity(residual, "GaussResidual", config, plotConfig, fileID, wg)
+	if !table {
+		return
+	}
+	residualNorm, residualMax := norms(residual)
+	rhoNorm, _ := norms(rho)
+	divBNorm, divBMax := norms(fieldop.DivergenceB(fields.codeVector("B"), grid))
+	cells := math.Sqrt(float64(len(rho) * len(rho[0]) * len(rho[0][0])))
+
+	relative := residualNorm
+	if rhoNorm > 0 {
+		relative /= rhoNorm
+	}
+	runtable.Append(plotConfig.OutputASCIIDirectory, "GaussLaw", fileID,
+		[]plotscript.Column{{Name: "time", Unit: "normalized"}, {Name: "|divE-rho|/|rho|"}, {Name: "max|divE-rho|"}, {Name: "rms(divB)"}, {Name: "max|divB|"}},
+		[]float64{float64(simulationTime), relative, residualMax, divBNorm / cells, divBMax})
+}
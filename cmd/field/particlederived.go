@@ -0,0 +1,94 @@
+package field
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/Penpen7/goplot/cmd/simulationconfig"
+)
+
+// 全粒子種のメッシュデータを組み合わせて求める量。規格化単位で計算します。
+var ParticleDerivedNames = [...]string{"ChargeDensity", "IonDensity", "ElectronDensity", "MeanZ"}
+
+// 1粒子あたりのエネルギー _Energy/_Density を求めます。ドリフトのエネルギーも含みます。
+// 粒子のいない格子点は0です。
+func temperature(mesh FieldSet) [][][]float32 {
+	energy, density := mesh["Energy"], mesh["Density"]
+	res := newArrayLike(density)
+	for x := range density {
+		for y := range density[x] {
+			for z := range density[x][y] {
+				if density[x][y][z] > 0 {
+					res[x][y][z] = energy[x][y][z] / density[x][y][z]
+				}
+			}
+		}
+	}
+	return res
+}
+
+// 粒子種first-lastについて、重みweight(is)をかけた密度の和を求めます。
+func (particles ParticleMeshSet) weightedDensity(first int32, last int32, weight func(species int32) float32) [][][]float32 {
+	var res [][][]float32
+	for species := first; species <= last; species++ {
+		density := particles[species]["Density"]
+		if res == nil {
+			res = newArrayLike(density)
+		}
+		w := weight(species)
+		for x := range density {
+			for y := range density[x] {
+				for z := range density[x][y] {
+					res[x][y][z] += w * density[x][y][z]
+				}
+			}
+		}
+	}
+	return res
+}
+
+// MeanZを出力しない警告を一度だけ出すため
+var meanZWarning sync.Once
+
+// 全粒子種を組み合わせて求める量を返します。
+// ChargeDensityは Σ q_s n_s、IonDensity, ElectronDensityはイオン、電子の密度の和、
+// MeanZはイオンの平均電荷数 Σ q_s n_s / Σ n_s (イオンのいない格子点は0)です。
+// q_sはgfin.datのParticleChargeで、電離によって変わる電荷数は出力に含まれていないため、
+// 電離を使う計算(UsedIonize)ではMeanZは初期の電荷数になってしまうので求めません。
+func (particles ParticleMeshSet) Derived(name string, config simulationconfig.SimulationConfig) ([][][]float32, bool) {
+	one := func(species int32) float32 { return 1 }
+	charge := func(species int32) float32 { return float32(config.Particle[species-1].ParticleCharge) }
+	switch name {
+	case "ChargeDensity":
+		return ChargeDensity(particles, config), true
+	case "IonDensity":
+		return particles.weightedDensity(1, config.IonNumber, one), config.IonNumber > 0
+	case "ElectronDensity":
+		return particles.weightedDensity(config.IonNumber+1, config.TotalParticleSpecies, one), config.TotalParticleSpecies > config.IonNumber
+	case "MeanZ":
+		if config.IonNumber == 0 {
+			return nil, false
+		}
+		if config.UsedIonize {
+			meanZWarning.Do(func() {
+				fmt.Printf("\n\x1b[35mwarning : 電離を使う計算では出力に電離後の電荷数が含まれないため、MeanZは出力しません。\x1b[0m\n")
+			})
+			return nil, false
+		}
+		res := particles.weightedDensity(1, config.IonNumber, charge)
+		density := particles.weightedDensity(1, config.IonNumber, one)
+		for x := range res {
+			for y := range res[x] {
+				for z := range res[x][y] {
+					if density[x][y][z] > 0 {
+						res[x][y][z] /= density[x][y][z]
+					} else {
+						res[x][y][z] = 0
+					}
+				}
+			}
+		}
+		return res, true
+	}
+	return nil, false
+}
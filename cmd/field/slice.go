@@ -0,0 +1,232 @@
+package field
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/Penpen7/goplot/cmd/physconst"
+)
+
+var axisName = [3]string{"x", "y", "z"}
+
+// 断面・線の切り出し方。
+// modeの軸(free)に沿って値を並べ、それ以外の軸は[lo, hi]の範囲をreduceで集約します。
+type sliceSpec struct {
+	mode   string
+	free   []int
+	lo, hi [3]int
+	reduce string
+}
+
+// 切り出しモードに対応する軸。xy, yz, zxは断面、x, y, zは線です。
+var sliceAxes = map[string][]int{
+	"xy": {0, 1}, "yz": {1, 2}, "zx": {2, 0},
+	"x": {0}, "y": {1}, "z": {2},
+}
+
+func axisIndex(name string) (int, bool) {
+	for i, v := range axisName {
+		if v == name {
+			return i, true
+		}
+	}
+	return 0, false
+}
+
+// 位置の指定を格子上の座標に変換します。
+// 12(格子番号), mid(中心), 0.25L(軸の長さに対する割合), 3.5um(µm)が指定できます。
+func parseCoordinate(s string, axis int, size int) (float64, error) {
+	switch {
+	case s == "mid":
+		return float64(size / 2), nil
+	case strings.HasSuffix(s, "L"):
+		f, err := strconv.ParseFloat(strings.TrimSuffix(s, "L"), 64)
+		if err != nil {
+			return 0, err
+		}
+		return math.Min(f*float64(size), float64(size-1)), nil
+	case strings.HasSuffix(s, "um"):
+		f, err := strconv.ParseFloat(strings.TrimSuffix(s, "um"), 64)
+		if err != nil {
+			return 0, err
+		}
+		if physconst.OutputMeshSpacing[axis] == 0 {
+			return 0, fmt.Errorf("%s軸の格子間隔が不明です", axisName[axis])
+		}
+		return f / float64(physconst.OutputMeshSpacing[axis]), nil
+	}
+	return strconv.ParseFloat(s, 64)
+}
+
+// 位置の指定を最も近い格子番号に変換します。
+func parsePosition(s string, axis int, size int) (int, error) {
+	c, err := parseCoordinate(s, axis, size)
+	if err != nil {
+		return 0, err
+	}
+	index := int(math.Round(c))
+	if index < 0 || index >= size {
+		return 0, fmt.Errorf("%sは%s軸の範囲(0-%d)外です", s, axisName[axis], size-1)
+	}
+	return index, nil
+}
+
+// 切り出しモードを解釈します。
+// xy, x などに続けて@で位置を指定できます。指定のない軸は中心になります。
+//
+//	xy@z=12         z=12の断面
+//	x@y=0.25L,z=mid y=0.25L, z=中心を通るx方向の線
+//	xy@z=10:20avg   z=10から20の平均
+//	xy@z=2um:4ummax z=2µmから4µmの最大値
+//	xy@z=avg        z方向全体の平均
+func parseSliceSpec(mode string, size [3]int) (sliceSpec, error) {
+	base, positions, hasPosition := strings.Cut(mode, "@")
+	free, ok := sliceAxes[base]
+	if !ok {
+		return sliceSpec{}, fmt.Errorf("invalid mode: %s", mode)
+	}
+	spec := sliceSpec{mode: base, free: free}
+	for axis := 0; axis < 3; axis++ {
+		spec.lo[axis], spec.hi[axis] = size[axis]/2, size[axis]/2
+	}
+	for _, axis := range free {
+		spec.lo[axis], spec.hi[axis] = 0, size[axis]-1
+	}
+	if !hasPosition {
+		return spec, nil
+	}
+	for _, v := range strings.Split(positions, ",") {
+		name, value, found := strings.Cut(v, "=")
+		axis, isAxis := axisIndex(name)
+		if !found || !isAxis {
+			return sliceSpec{}, fmt.Errorf("invalid position: %s", v)
+		}
+		for _, f := range free {
+			if f == axis {
+				return sliceSpec{}, fmt.Errorf("%s軸は%sの切り出し方向です", name, base)
+			}
+		}
+		reduce := ""
+		for _, r := range []string{"avg", "max"} {
+			if strings.HasSuffix(value, r) {
+				reduce = r
+				value = strings.TrimSuffix(value, r)
+			}
+		}
+		if reduce != "" {
+			if spec.reduce != "" && spec.reduce != reduce {
+				return sliceSpec{}, fmt.Errorf("avgとmaxは同時に指定できません: %s", mode)
+			}
+			spec.reduce = reduce
+		}
+		var err error
+		switch {
+		case value == "" && reduce != "":
+			spec.lo[axis], spec.hi[axis] = 0, size[axis]-1
+		case strings.Contains(value, ":"):
+			from, to, _ := strings.Cut(value, ":")
+			if spec.lo[axis], err = parsePosition(from, axis, size[axis]); err != nil {
+				return sliceSpec{}, err
+			}
+			if spec.hi[axis], err = parsePosition(to, axis, size[axis]); err != nil {
+				return sliceSpec{}, err
+			}
+			if spec.lo[axis] > spec.hi[axis] {
+				spec.lo[axis], spec.hi[axis] = spec.hi[axis], spec.lo[axis]
+			}
+		default:
+			if spec.lo[axis], err = parsePosition(value, axis, size[axis]); err != nil {
+				return sliceSpec{}, err
+			}
+			spec.hi[axis] = spec.lo[axis]
+		}
+	}
+	if spec.reduce == "" {
+		for axis := 0; axis < 3; axis++ {
+			if spec.lo[axis] != spec.hi[axis] && !spec.isFree(axis) {
+				spec.reduce = "avg"
+			}
+		}
+	}
+	return spec, nil
+}
+
+func (spec sliceSpec) isFree(axis int) bool {
+	for _, f := range spec.free {
+		if f == axis {
+			return true
+		}
+	}
+	return false
+}
+
+// 切り出し方向の格子点ごとの値を求めます。戻り値は[free[0]][free[1]]の順です。
+func (spec sliceSpec) extract(g [][][]float32) [][]float32 {
+	n0 := spec.hi[spec.free[0]] + 1
+	n1 := 1
+	if len(spec.free) == 2 {
+		n1 = spec.hi[spec.free[1]] + 1
+	}
+	res := make([][]float32, n0)
+	for i := range res {
+		res[i] = make([]float32, n1)
+		for j := range res[i] {
+			lo, hi := spec.lo, spec.hi
+			lo[spec.free[0]], hi[spec.free[0]] = i, i
+			if len(spec.free) == 2 {
+				lo[spec.free[1]], hi[spec.free[1]] = j, j
+			}
+			res[i][j] = reduceBox(g, lo, hi, spec.reduce)
+		}
+	}
+	return res
+}
+
+// 直方体[lo, hi]内の値を平均または最大値で集約します。
+func reduceBox(g [][][]float32, lo [3]int, hi [3]int, reduce string) float32 {
+	sum := float32(0)
+	max := float32(math.Inf(-1))
+	count := 0
+	for x := lo[0]; x <= hi[0]; x++ {
+		for y := lo[1]; y <= hi[1]; y++ {
+			for z := lo[2]; z <= hi[2]; z++ {
+				sum += g[x][y][z]
+				if g[x][y][z] > max {
+					max = g[x][y][z]
+				}
+				count++
+			}
+		}
+	}
+	if reduce == "max" {
+		return max
+	}
+	return sum / float32(count)
+}
+
+// ヘッダに書く切り出し位置の説明
+func (spec sliceSpec) describe() string {
+	parts := []string{}
+	for axis := 0; axis < 3; axis++ {
+		if spec.isFree(axis) {
+			continue
+		}
+		if spec.lo[axis] == spec.hi[axis] {
+			parts = append(parts, fmt.Sprintf("%s=%d", axisName[axis], spec.lo[axis]))
+		} else {
+			parts = append(parts, fmt.Sprintf("%s=%d:%d", axisName[axis], spec.lo[axis], spec.hi[axis]))
+		}
+	}
+	description := strings.Join(parts, ", ")
+	if spec.reduce != "" {
+		description += " " + spec.reduce
+	}
+	return description
+}
+
+// 切り出しモードをファイル名に使える文字列に変換します。
+func fileTag(mode string) string {
+	return strings.NewReplacer("@", "_", "=", "", ":", "-", ",", "_").Replace(mode)
+}
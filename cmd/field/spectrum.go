@@ -0,0 +1,223 @@
+package field
+
+import (
+	"bufio"
+	"fmt"
+	"math"
+	"math/cmplx"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/Penpen7/goplot/cmd/fft"
+	"github.com/Penpen7/goplot/cmd/fieldop"
+	"github.com/Penpen7/goplot/cmd/npy"
+	"github.com/Penpen7/goplot/cmd/physconst"
+	"github.com/Penpen7/goplot/cmd/plotconfig"
+	"github.com/Penpen7/goplot/cmd/plotscript"
+	"github.com/Penpen7/goplot/cmd/render"
+	"github.com/Penpen7/goplot/cmd/simulationconfig"
+)
+
+// 波数の軸。レーザー波長が分かればk/k0、分からなければk(1/µm)です。
+func waveNumberAxis(n int, axis int, signed bool) ([]float32, string) {
+	dx := float64(physconst.OutputMeshSpacing[axis])
+	if dx == 0 {
+		dx = 1
+	}
+	scale, unit := 2*math.Pi/(float64(n)*dx), "1/um"
+	if physconst.LaserWavelength > 0 {
+		scale, unit = float64(physconst.LaserWavelength)/(float64(n)*dx), "k0"
+	}
+	k := []float32{}
+	if signed {
+		for m := -n / 2; m < n-n/2; m++ {
+			k = append(k, float32(float64(m)*scale))
+		}
+	} else {
+		for m := 0; m <= n/2; m++ {
+			k = append(k, float32(float64(m)*scale))
+		}
+	}
+	return k, unit
+}
+
+// axis方向の1次元パワースペクトル |F(k)|^2/N^2 を、他の2軸について平均して求めます。
+// k >= 0 の片側のみを返します。
+func lineSpectrum(g [][][]float32, axis int, window string) []float32 {
+	size := [3]int{len(g), len(g[0]), len(g[0][0])}
+	n := size[axis]
+	w := fft.Window(window, n)
+	power := make([]float64, n/2+1)
+	a, b := (axis+1)%3, (axis+2)%3
+	for i := 0; i < size[a]; i++ {
+		for j := 0; j < size[b]; j++ {
+			line := make([]complex128, n)
+			for k := 0; k < n; k++ {
+				var p [3]int
+				p[axis], p[a], p[b] = k, i, j
+				line[k] = complex(float64(g[p[0]][p[1]][p[2]])*w[k], 0)
+			}
+			for k, v := range fft.Transform(line)[:n/2+1] {
+				power[k] += math.Pow(cmplx.Abs(v)/float64(n), 2)
+			}
+		}
+	}
+	res := make([]float32, len(power))
+	for k, v := range power {
+		res[k] = float32(v / float64(size[a]*size[b]))
+	}
+	return res
+}
+
+// 2次元のパワースペクトル |F(ka, kb)|^2/(NaNb)^2 を求めます。
+// 戻り値は負の波数から並べた[ka][kb]の配列です。
+func planeSpectrum(plane [][]float32, window string) [][]float32 {
+	na, nb := len(plane), len(plane[0])
+	wa, wb := fft.Window(window, na), fft.Window(window, nb)
+	data := make([][]complex128, na)
+	for i := range data {
+		row := make([]complex128, nb)
+		for j := range row {
+			row[j] = complex(float64(plane[i][j])*wa[i]*wb[j], 0)
+		}
+		data[i] = fft.Transform(row)
+	}
+	for j := 0; j < nb; j++ {
+		column := make([]complex128, na)
+		for i := range column {
+			column[i] = data[i][j]
+		}
+		for i, v := range fft.Transform(column) {
+			data[i][j] = v
+		}
+	}
+	res := make([][]float32, na)
+	for i := range res {
+		res[i] = make([]float32, nb)
+	}
+	for i := 0; i < na; i++ {
+		for j := 0; j < nb; j++ {
+			// 負の波数が先頭に来るように並べ替える
+			si := fft.SignedIndex(i, na) + na/2
+			sj := fft.SignedIndex(j, nb) + nb/2
+			res[si][sj] = float32(math.Pow(cmplx.Abs(data[i][j])/float64(na*nb), 2))
+		}
+	}
+	return res
+}
+
+// 物理量の名前から3次元配列を探します。派生量や差分演算の量も指定できます。名前は完全に一致するものだけを探します。
+func (fields FieldSet) Lookup(name string, grid fieldop.Grid) ([][][]float32, bool) {
+	if g, found := fields.Derived(name); found {
+		return g, true
+	}
+	return fields.Operator(name, grid)
+}
+
+// 窓関数の指定を返します。
+func spectrumWindow(center string) string {
+	for _, w := range []string{"hann", "hamming"} {
+		if plotconfig.HasFlag(center, w) {
+			return w
+		}
+	}
+	return "none"
+}
+
+// plot.jsonのSpectrumに従って空間スペクトルを出力します。
+// kx, ky, kzは1次元スペクトル、kxky, kykz, kzkxは断面(@で位置を指定可能)の2次元スペクトルです。
+func WriteFieldSpectrum(fields FieldSet, config simulationconfig.SimulationConfig, plotConfig plotconfig.Art, fileID int, wg *sync.WaitGroup) {
+	grid := fieldop.NewGrid(config, plotConfig.FieldBoundary, plotConfig.FieldStaggering)
+	for _, vconfig := range plotConfig.Spectrum {
+		if !vconfig.Plot {
+			continue
+		}
+		g, found := fields.Lookup(vconfig.Name, grid)
+		if !found {
+			continue
+		}
+		fmt.Printf("\r\033[K calculating... %s spectrum", vconfig.Name)
+		window := spectrumWindow(vconfig.Center)
+		size := [3]int{len(g), len(g[0]), len(g[0][0])}
+		for _, mode := range plotconfig.Modes(vconfig.Center) {
+			name := fmt.Sprintf("%s_spectrum_%s", vconfig.Name, fileTag(mode))
+			base, position, _ := strings.Cut(mode, "@")
+			switch base {
+			case "kx", "ky", "kz":
+				axis, _ := axisIndex(base[1:])
+				k, unit := waveNumberAxis(size[axis], axis, false)
+				power := lineSpectrum(g, axis, window)
+				wg.Add(1)
+				go writeSpectrum1D(k, power, fmt.Sprintf("# %s/%s power window=%s\n", base, unit, window), fmt.Sprintf("%s/%s_%04d.txt", plotConfig.OutputASCIIDirectory, name, fileID), wg)
+				plotscript.Register(plotscript.Kind{Name: name, Pattern: name + "_%04d.txt", Format: plotscript.Line, LogY: true,
+					Columns: []plotscript.Column{{Name: base, Unit: unit}, {Name: "|F|^2 " + vconfig.Name}}}, fileID)
+				if plotconfig.HasFlag(vconfig.Center, "npy") {
+					wg.Add(1)
+					go npy.WriteArray1D(power, fmt.Sprintf("%s/%s_%04d.npy", plotConfig.OutputNPYDirectory, name, fileID), wg)
+				}
+			case "kxky", "kykz", "kzkx":
+				planeMode := base[1:2] + base[3:4]
+				if position != "" {
+					planeMode += "@" + position
+				}
+				spec, err := parseSliceSpec(planeMode, size)
+				if err != nil {
+					fmt.Println("Warning:invalid mode:", mode, err)
+					continue
+				}
+				power := planeSpectrum(spec.extract(g), window)
+				ka, unit := waveNumberAxis(len(power), spec.free[0], true)
+				kb, _ := waveNumberAxis(len(power[0]), spec.free[1], true)
+				wg.Add(1)
+				go writeSpectrum2D(ka, kb, power, fmt.Sprintf("# %s/%s %s/%s power window=%s %s\n", base[:2], unit, base[2:], unit, window, spec.describe()),
+					fmt.Sprintf("%s/%s_%04d.txt", plotConfig.OutputASCIIDirectory, name, fileID), wg)
+				plotscript.Register(plotscript.Kind{Name: name, Pattern: name + "_%04d.txt", Format: plotscript.Map, Slice: mode,
+					Columns: []plotscript.Column{{Name: base[:2], Unit: unit}, {Name: base[2:], Unit: unit}, {Name: "|F|^2 " + vconfig.Name}}}, fileID)
+				if plotconfig.HasFlag(vconfig.Center, "npy") {
+					wg.Add(1)
+					go npy.WriteArray2D(power, fmt.Sprintf("%s/%s_%04d.npy", plotConfig.OutputNPYDirectory, name, fileID), wg)
+				}
+				if plotconfig.HasFlag(vconfig.Center, "png") {
+					wg.Add(1)
+					go render.WriteHeatmap(power, render.Options{Log: true}, fmt.Sprintf("%s/%s_%04d.png", plotConfig.OutputPNGDirectory, name, fileID), wg)
+				}
+			default:
+				fmt.Println("Warning:invalid spectrum mode:", mode)
+			}
+		}
+	}
+}
+
+func writeSpectrum1D(k []float32, power []float32, header string, fname string, wg *sync.WaitGroup) {
+	fout, err := os.Create(fname)
+	defer fout.Close()
+	if err != nil {
+		panic(err)
+	}
+	writer := bufio.NewWriter(fout)
+	writer.WriteString(header)
+	for i, v := range power {
+		writer.WriteString(fmt.Sprintln(k[i], v))
+	}
+	writer.Flush()
+	wg.Done()
+}
+
+func writeSpectrum2D(ka []float32, kb []float32, power [][]float32, header string, fname string, wg *sync.WaitGroup) {
+	fout, err := os.Create(fname)
+	defer fout.Close()
+	if err != nil {
+		panic(err)
+	}
+	writer := bufio.NewWriter(fout)
+	writer.WriteString(header)
+	for i, row := range power {
+		for j, v := range row {
+			writer.WriteString(fmt.Sprintln(ka[i], kb[j], v))
+		}
+		writer.WriteString("\n")
+	}
+	writer.Flush()
+	wg.Done()
+}
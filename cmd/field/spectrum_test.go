@@ -0,0 +1,40 @@
+package field
+
+import (
+	"math"
+	"testing"
+
+	"github.com/Penpen7/goplot/cmd/physconst"
+)
+
+// レーザー波長と同じ波長の正弦波のピークがk/k0 = 1に来ることを確かめます。
+func TestLineSpectrumLaserMode(t *testing.T) {
+	spacing, wavelength := physconst.OutputMeshSpacing, physconst.LaserWavelength
+	defer func() { physconst.OutputMeshSpacing, physconst.LaserWavelength = spacing, wavelength }()
+	physconst.OutputMeshSpacing = [3]float32{0.1, 0.1, 0.1}
+	physconst.LaserWavelength = 0.8
+
+	const n = 64
+	g := make([][][]float32, n)
+	for x := range g {
+		g[x] = [][]float32{{float32(math.Cos(2 * math.Pi * float64(x) * 0.1 / 0.8))}}
+	}
+	power := lineSpectrum(g, 0, "none")
+	k, unit := waveNumberAxis(n, 0, false)
+	if unit != "k0" || len(k) != len(power) {
+		t.Fatalf("unit = %s, len(k) = %d, len(power) = %d", unit, len(k), len(power))
+	}
+	peak := 0
+	for i, v := range power {
+		if v > power[peak] {
+			peak = i
+		}
+	}
+	if math.Abs(float64(k[peak])-1) > 1e-6 {
+		t.Errorf("peak at k = %g k0, want 1", k[peak])
+	}
+	// cosの振幅1は正負の波数に1/2ずつ分かれる
+	if math.Abs(float64(power[peak])-0.25) > 1e-6 {
+		t.Errorf("peak power = %g, want 0.25", power[peak])
+	}
+}
@@ -0,0 +1,137 @@
+package field
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"sync"
+
+	"github.com/Penpen7/goplot/cmd/fieldop"
+	"github.com/Penpen7/goplot/cmd/npy"
+	"github.com/Penpen7/goplot/cmd/plotconfig"
+	"github.com/Penpen7/goplot/cmd/plotscript"
+	"github.com/Penpen7/goplot/cmd/render"
+	"github.com/Penpen7/goplot/cmd/simulationconfig"
+)
+
+// 1つの物理量の1つの切り出し方についての時空間図
+type streak struct {
+	quantity  string
+	unit      string
+	mode      string
+	center    string
+	axis      string
+	positions []float64
+	times     []float32
+	rows      [][]float32
+}
+
+var (
+	streakMutex sync.Mutex
+	streaks     = map[string]*streak{}
+	streakOrder []string
+)
+
+// plot.jsonのStreakに指定された1次元の切り出しを、ステップごとに記録します。
+func RecordStreak(fields FieldSet, particles ParticleMeshSet, config simulationconfig.SimulationConfig, plotConfig plotconfig.Art, simulationTime float32) {
+	streakMutex.Lock()
+	defer streakMutex.Unlock()
+	grid := fieldop.NewGrid(config, plotConfig.FieldBoundary, plotConfig.FieldStaggering)
+	for _, vconfig := range plotConfig.Streak {
+		if !vconfig.Plot {
+			continue
+		}
+		g, found := particles.Lookup(vconfig.Name, config, plotConfig)
+		if !found {
+			g, found = particles.Derived(vconfig.Name, config)
+		}
+		if !found {
+			g, found = fields.Lookup(vconfig.Name, grid)
+		}
+		if !found {
+			fmt.Println("Warning:unknown streak quantity:", vconfig.Name)
+			continue
+		}
+		g, unit, _ := inDensityUnit(g, vconfig.Name, plotConfig)
+		for _, mode := range plotconfig.Modes(vconfig.Center) {
+			axis, positions, values, ok := cut1D(g, mode)
+			if !ok {
+				fmt.Println("Warning:invalid streak mode:", mode)
+				continue
+			}
+			key := fmt.Sprintf("Streak_%s_%s", vconfig.Name, fileTag(mode))
+			s, exists := streaks[key]
+			if !exists {
+				s = &streak{quantity: vconfig.Name, unit: unit, mode: mode, center: vconfig.Center, axis: axis, positions: positions}
+				streaks[key] = s
+				streakOrder = append(streakOrder, key)
+			}
+			if len(values) != len(s.positions) {
+				fmt.Println("Warning:streak length changed:", key)
+				continue
+			}
+			s.times = append(s.times, simulationTime)
+			s.rows = append(s.rows, values)
+		}
+	}
+}
+
+// 記録した時空間図を書き出します。テキストは時刻ごとのブロック、
+// npyは[時刻][位置]の配列と時刻・位置の軸、PNGは横軸が位置、縦軸が時刻の画像です。
+func WriteStreaks(plotConfig plotconfig.Art) error {
+	streakMutex.Lock()
+	defer streakMutex.Unlock()
+	wg := &sync.WaitGroup{}
+	for _, key := range streakOrder {
+		s := streaks[key]
+		if len(s.rows) == 0 {
+			continue
+		}
+		if err := s.writeText(fmt.Sprintf("%s/%s.txt", plotConfig.OutputASCIIDirectory, key)); err != nil {
+			return err
+		}
+		plotscript.Register(plotscript.Kind{Name: key, Pattern: key + ".txt", Format: plotscript.Map, Slice: s.mode,
+			Columns: []plotscript.Column{{Name: "time", Unit: "normalized"}, {Name: s.axis, Unit: "µm"}, {Name: s.quantity, Unit: s.unit}}}, 0)
+		if plotconfig.HasFlag(s.center, "npy") {
+			positions := make([]float32, len(s.positions))
+			for i, v := range s.positions {
+				positions[i] = float32(v)
+			}
+			wg.Add(3)
+			go npy.WriteArray2D(s.rows, fmt.Sprintf("%s/%s.npy", plotConfig.OutputNPYDirectory, key), wg)
+			go npy.WriteArray1D(s.times, fmt.Sprintf("%s/%s_time.npy", plotConfig.OutputNPYDirectory, key), wg)
+			go npy.WriteArray1D(positions, fmt.Sprintf("%s/%s_%s.npy", plotConfig.OutputNPYDirectory, key, s.axis), wg)
+		}
+		if plotconfig.HasFlag(s.center, "png") {
+			image := make([][]float32, len(s.positions))
+			for i := range image {
+				image[i] = make([]float32, len(s.rows))
+				for t, row := range s.rows {
+					image[i][t] = row[i]
+				}
+			}
+			wg.Add(1)
+			go render.WriteHeatmap(image, render.Options{Scale: 2}, fmt.Sprintf("%s/%s.png", plotConfig.OutputPNGDirectory, key), wg)
+		}
+	}
+	wg.Wait()
+	return nil
+}
+
+func (s *streak) writeText(fname string) error {
+	fout, err := os.Create(fname)
+	if err != nil {
+		return err
+	}
+	defer fout.Close()
+	writer := bufio.NewWriter(fout)
+	writer.WriteString(fmt.Sprintf("# streak %s : %s\n", s.quantity, s.mode))
+	writer.WriteString(fmt.Sprintf("# time(normalized) %s(µm) %s(%s)\n", s.axis, s.quantity, s.unit))
+	for t, row := range s.rows {
+		for i, v := range row {
+			writer.WriteString(fmt.Sprintln(s.times[t], s.positions[i], v))
+		}
+		writer.WriteString("\n")
+	}
+	return writer.Flush()
+}
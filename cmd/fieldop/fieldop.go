@@ -0,0 +1,189 @@
+package fieldop
+
+import (
+	"strings"
+
+	"github.com/Penpen7/goplot/cmd/simulationconfig"
+)
+
+// 差分の取り方
+type Scheme int
+
+const (
+	Central  Scheme = iota // 中心差分 (g[i+1]-g[i-1])/2dx。全ての量が同じ格子点にある場合
+	Forward                // 前進差分 (g[i+1]-g[i])/dx。結果は半格子先の点の値になります
+	Backward               // 後退差分 (g[i]-g[i-1])/dx。結果は半格子手前の点の値になります
+)
+
+// 差分に使う格子の情報
+type Grid struct {
+	Spacing  [3]float64
+	Periodic [3]bool
+	// trueのとき、E, BはYee格子上の値として扱います。
+	// Eは辺の中点、Bは面の中心にあるとみなして前進・後退差分を使い分けます。
+	Staggered bool
+}
+
+// シミュレーションの設定から、規格化された長さ単位での出力メッシュの格子を作ります。
+// boundaryはperiodic, open, autoのいずれかで、autoのときはFildBoundaryConditionが0なら周期境界とみなします。
+// staggeringがyeeのときYee格子として扱い、それ以外(collocated)では全成分が同じ格子点にあるとします。
+func NewGrid(config simulationconfig.SimulationConfig, boundary string, staggering string) Grid {
+	var grid Grid
+	for axis := 0; axis < 3; axis++ {
+		grid.Spacing[axis] = 1.0
+		if config.OutputMeshNumber[axis] > 0 && config.SystemL[axis] > 0 {
+			grid.Spacing[axis] = config.SystemL[axis] / float64(config.OutputMeshNumber[axis])
+		}
+		switch strings.ToLower(boundary) {
+		case "periodic":
+			grid.Periodic[axis] = true
+		case "open":
+			grid.Periodic[axis] = false
+		default:
+			grid.Periodic[axis] = config.FildBoundaryCondition == 0
+		}
+	}
+	grid.Staggered = strings.ToLower(staggering) == "yee"
+	return grid
+}
+
+// Yee格子でない場合はすべて中心差分にします。
+func (grid Grid) scheme(yee Scheme) Scheme {
+	if grid.Staggered {
+		return yee
+	}
+	return Central
+}
+
+func newArray(g [][][]float32) [][][]float32 {
+	res := make([][][]float32, len(g))
+	for x := range g {
+		res[x] = make([][]float32, len(g[x]))
+		for y := range g[x] {
+			res[x][y] = make([]float32, len(g[x][y]))
+		}
+	}
+	return res
+}
+
+// 軸方向にoffsetだけずらした格子番号を返します。開放境界で範囲外になる場合はfalseを返します。
+func (grid Grid) neighbor(i int, offset int, size int, axis int) (int, bool) {
+	j := i + offset
+	if j >= 0 && j < size {
+		return j, true
+	}
+	if grid.Periodic[axis] {
+		return (j%size + size) % size, true
+	}
+	return 0, false
+}
+
+// axis方向の偏微分を差分で求めます。開放境界の端では内側への片側差分になります。
+func Derivative(g [][][]float32, axis int, scheme Scheme, grid Grid) [][][]float32 {
+	res := newArray(g)
+	size := [3]int{len(g), len(g[0]), len(g[0][0])}
+	if size[axis] == 1 {
+		return res
+	}
+	lo, hi := -1, 1
+	switch scheme {
+	case Forward:
+		lo = 0
+	case Backward:
+		hi = 0
+	}
+	for x := 0; x < size[0]; x++ {
+		for y := 0; y < size[1]; y++ {
+			for z := 0; z < size[2]; z++ {
+				p := [3]int{x, y, z}
+				plus, minus := p, p
+				var okPlus, okMinus bool
+				plus[axis], okPlus = grid.neighbor(p[axis], hi, size[axis], axis)
+				minus[axis], okMinus = grid.neighbor(p[axis], lo, size[axis], axis)
+				distance := float64(hi - lo)
+				if !okPlus || !okMinus {
+					if !okPlus {
+						plus[axis] = p[axis]
+					}
+					if !okMinus {
+						minus[axis] = p[axis]
+					}
+					if plus[axis] == minus[axis] {
+						if okPlus {
+							plus[axis] = p[axis] + 1
+						} else {
+							minus[axis] = p[axis] - 1
+						}
+					}
+					distance = float64(plus[axis] - minus[axis])
+				}
+				res[x][y][z] = float32((float64(g[plus[0]][plus[1]][plus[2]]) - float64(g[minus[0]][minus[1]][minus[2]])) / (distance * grid.Spacing[axis]))
+			}
+		}
+	}
+	return res
+}
+
+// 勾配 ∇g。スカラーは格子点上にあるとみなし、Yee格子では前進差分になります。
+func Gradient(g [][][]float32, grid Grid) [3][][][]float32 {
+	var res [3][][][]float32
+	for axis := 0; axis < 3; axis++ {
+		res[axis] = Derivative(g, axis, grid.scheme(Forward), grid)
+	}
+	return res
+}
+
+// 発散 ∇・v。Yee格子ではschemeの向きの差分を使います。
+func divergence(v [3][][][]float32, scheme Scheme, grid Grid) [][][]float32 {
+	res := newArray(v[0])
+	for axis := 0; axis < 3; axis++ {
+		d := Derivative(v[axis], axis, scheme, grid)
+		for x := range res {
+			for y := range res[x] {
+				for z := range res[x][y] {
+					res[x][y][z] += d[x][y][z]
+				}
+			}
+		}
+	}
+	return res
+}
+
+// 回転 ∇×v。Yee格子ではschemeの向きの差分を使います。
+func curl(v [3][][][]float32, scheme Scheme, grid Grid) [3][][][]float32 {
+	var res [3][][][]float32
+	for axis := 0; axis < 3; axis++ {
+		a, b := (axis+1)%3, (axis+2)%3
+		da := Derivative(v[b], a, scheme, grid)
+		db := Derivative(v[a], b, scheme, grid)
+		res[axis] = newArray(v[0])
+		for x := range da {
+			for y := range da[x] {
+				for z := range da[x][y] {
+					res[axis][x][y][z] = da[x][y][z] - db[x][y][z]
+				}
+			}
+		}
+	}
+	return res
+}
+
+// 電場の発散 ∇・E。Yee格子ではEが辺の中点にあるので後退差分で格子点の値を求めます。
+func DivergenceE(e [3][][][]float32, grid Grid) [][][]float32 {
+	return divergence(e, grid.scheme(Backward), grid)
+}
+
+// 磁場の発散 ∇・B。Yee格子ではBが面の中心にあるので前進差分でセル中心の値を求めます。
+func DivergenceB(b [3][][][]float32, grid Grid) [][][]float32 {
+	return divergence(b, grid.scheme(Forward), grid)
+}
+
+// 電場の回転 ∇×E。Yee格子では前進差分でBと同じ位置の値を求めます。
+func CurlE(e [3][][][]float32, grid Grid) [3][][][]float32 {
+	return curl(e, grid.scheme(Forward), grid)
+}
+
+// 磁場の回転 ∇×B。Yee格子では後退差分でEと同じ位置の値を求めます。
+func CurlB(b [3][][][]float32, grid Grid) [3][][][]float32 {
+	return curl(b, grid.scheme(Backward), grid)
+}
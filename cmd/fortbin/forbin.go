@@ -35,3 +35,18 @@ func ReadNextChunk(file *os.File) *bytes.Buffer {
 	}
 	return bytes.NewBuffer(m)
 }
+
+// 次のレコードを読み込まずに読み飛ばします。ファイルの終端に達した場合はfalseを返します。
+func SkipNextChunk(file *os.File) bool {
+	l := make([]byte, 4)
+	if _, err := io.ReadFull(file, l); err != nil {
+		return false
+	}
+	var size int32
+	binary.Read(bytes.NewBuffer(l), binary.LittleEndian, &size)
+	// 本体とフッタを読み飛ばす
+	if _, err := file.Seek(int64(size)+4, io.SeekCurrent); err != nil {
+		panic(err)
+	}
+	return true
+}
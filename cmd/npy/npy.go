@@ -0,0 +1,74 @@
+package npy
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+)
+
+// float32の配列をNumPyの.npy形式(バージョン1.0, C順)で書き出します。
+func Write(fname string, shape []int, data []float32) error {
+	fout, err := os.Create(fname)
+	if err != nil {
+		return err
+	}
+	defer fout.Close()
+	writer := bufio.NewWriter(fout)
+
+	dims := make([]string, len(shape))
+	for i, v := range shape {
+		dims[i] = fmt.Sprint(v)
+	}
+	shapeString := strings.Join(dims, ", ")
+	if len(shape) == 1 {
+		shapeString += ","
+	}
+	header := fmt.Sprintf("{'descr': '<f4', 'fortran_order': False, 'shape': (%s), }", shapeString)
+	// マジック(6) + バージョン(2) + ヘッダ長(2) + ヘッダ + 改行 が64の倍数になるように空白で埋める
+	padding := 64 - (10+len(header)+1)%64
+	header += strings.Repeat(" ", padding%64) + "\n"
+
+	writer.WriteString("\x93NUMPY")
+	writer.Write([]byte{1, 0})
+	binary.Write(writer, binary.LittleEndian, uint16(len(header)))
+	writer.WriteString(header)
+	binary.Write(writer, binary.LittleEndian, data)
+	return writer.Flush()
+}
+
+// 1次元配列を書き出します。
+func WriteArray1D(data []float32, fname string, wg *sync.WaitGroup) {
+	if err := Write(fname, []int{len(data)}, data); err != nil {
+		panic(err)
+	}
+	wg.Done()
+}
+
+// 2次元配列data[i][j]を形状(len(data), len(data[0]))で書き出します。
+func WriteArray2D(data [][]float32, fname string, wg *sync.WaitGroup) {
+	flat := make([]float32, 0, len(data)*len(data[0]))
+	for _, row := range data {
+		flat = append(flat, row...)
+	}
+	if err := Write(fname, []int{len(data), len(data[0])}, flat); err != nil {
+		panic(err)
+	}
+	wg.Done()
+}
+
+// 3次元配列g[x][y][z]を形状(nx, ny, nz)で書き出します。
+func WriteArray3D(g [][][]float32, fname string, wg *sync.WaitGroup) {
+	flat := make([]float32, 0, len(g)*len(g[0])*len(g[0][0]))
+	for x := range g {
+		for y := range g[x] {
+			flat = append(flat, g[x][y]...)
+		}
+	}
+	if err := Write(fname, []int{len(g), len(g[0]), len(g[0][0])}, flat); err != nil {
+		panic(err)
+	}
+	wg.Done()
+}
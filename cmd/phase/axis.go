@@ -0,0 +1,24 @@
+package phase
+
+// 運動量・速度のビンの軸を求めます。幅dltのn個のビンを0を中心に対称に並べます。
+// edgesはn+1個のビン境界 (k - n/2)·dlt (k = 0, ..., n)、
+// centresはn個のビン中心 (i + 1/2 - n/2)·dlt (i = 0, ..., n-1) です。
+// nは整数の割り算をせずに2で割るため、nが奇数のときは中央のビンの中心が0に、
+// 偶数のときは中央のビン境界が0になります。
+// 以前の式 (i - n/2 - 1/2)·dlt (n/2は整数の割り算) は1から数える添字の式を0から数える添字に使っていたため、
+// 偶数のnで1ビン、奇数のnで半ビン負の側にずれていました。
+//
+//	n=4, dlt=1: edges -2 -1 0 1 2, centres -1.5 -0.5 0.5 1.5
+//	n=3, dlt=1: edges -1.5 -0.5 0.5 1.5, centres -1 0 1
+func binAxis(dlt float64, n int) (edges []float64, centres []float64) {
+	half := float64(n) / 2
+	edges = make([]float64, n+1)
+	for k := range edges {
+		edges[k] = (float64(k) - half) * dlt
+	}
+	centres = make([]float64, n)
+	for i := range centres {
+		centres[i] = (float64(i) + 0.5 - half) * dlt
+	}
+	return edges, centres
+}
@@ -0,0 +1,50 @@
+package phase
+
+import (
+	"math"
+	"reflect"
+	"testing"
+)
+
+func TestBinAxis(t *testing.T) {
+	tests := []struct {
+		dlt     float64
+		n       int
+		edges   []float64
+		centres []float64
+	}{
+		{1, 3, []float64{-1.5, -0.5, 0.5, 1.5}, []float64{-1, 0, 1}},
+		{1, 4, []float64{-2, -1, 0, 1, 2}, []float64{-1.5, -0.5, 0.5, 1.5}},
+		{1, 5, []float64{-2.5, -1.5, -0.5, 0.5, 1.5, 2.5}, []float64{-2, -1, 0, 1, 2}},
+		{0.5, 4, []float64{-1, -0.5, 0, 0.5, 1}, []float64{-0.75, -0.25, 0.25, 0.75}},
+	}
+	for _, tt := range tests {
+		edges, centres := binAxis(tt.dlt, tt.n)
+		if !reflect.DeepEqual(edges, tt.edges) {
+			t.Errorf("binAxis(%g, %d) edges = %v, want %v", tt.dlt, tt.n, edges, tt.edges)
+		}
+		if !reflect.DeepEqual(centres, tt.centres) {
+			t.Errorf("binAxis(%g, %d) centres = %v, want %v", tt.dlt, tt.n, centres, tt.centres)
+		}
+	}
+}
+
+// 軸はp=0について対称で、ビン中心はとなり合うビン境界の中点です。
+func TestBinAxisSymmetry(t *testing.T) {
+	for n := 1; n <= 8; n++ {
+		edges, centres := binAxis(0.3, n)
+		for k := range edges {
+			if math.Abs(edges[k]+edges[n-k]) > 1e-12 {
+				t.Errorf("n=%d: edges[%d]=%g and edges[%d]=%g are not symmetric", n, k, edges[k], n-k, edges[n-k])
+			}
+		}
+		for i := range centres {
+			if math.Abs(centres[i]+centres[n-1-i]) > 1e-12 {
+				t.Errorf("n=%d: centres[%d]=%g and centres[%d]=%g are not symmetric", n, i, centres[i], n-1-i, centres[n-1-i])
+			}
+			if math.Abs(centres[i]-(edges[i]+edges[i+1])/2) > 1e-12 {
+				t.Errorf("n=%d: centres[%d]=%g is not the midpoint of its edges", n, i, centres[i])
+			}
+		}
+	}
+}
@@ -0,0 +1,103 @@
+package phase
+
+import (
+	"bufio"
+	"fmt"
+	"math"
+	"os"
+	"sync"
+)
+
+// 軸axis上の分布fの平均、標準偏差と総数を求めます。
+func moments(axis []float32, f []float32) (mean float64, spread float64, count float64) {
+	sum, square := 0.0, 0.0
+	for i, v := range f {
+		count += float64(v)
+		sum += float64(v) * float64(axis[i])
+		square += float64(v) * float64(axis[i]) * float64(axis[i])
+	}
+	if count == 0 {
+		return 0, 0, 0
+	}
+	mean = sum / count
+	return mean, math.Sqrt(math.Max(square/count-mean*mean, 0)), count
+}
+
+// 標準偏差(p/mcまたはv/c)から温度(keV)を求めます。restEnergyは粒子の静止エネルギー(keV)です。
+func temperature(spread float64, restEnergy float64) float64 {
+	return restEnergy * spread * spread
+}
+
+// buf[i][j]をiについて足し合わせた、jの分布を返します。
+func marginalSecond(buf [][]float32) []float32 {
+	res := make([]float32, len(buf[0]))
+	for i := range buf {
+		for j, v := range buf[i] {
+			res[j] += v
+		}
+	}
+	return res
+}
+
+// buf[i][j]をjについて足し合わせた、iの分布を返します。
+func marginalFirst(buf [][]float32) []float32 {
+	res := make([]float32, len(buf))
+	for i := range buf {
+		for _, v := range buf[i] {
+			res[i] += v
+		}
+	}
+	return res
+}
+
+func writeMarginal(axis []float32, f []float32, header string, fname string, wg *sync.WaitGroup) {
+	fout, err := os.Create(fname)
+	defer fout.Close()
+	if err != nil {
+		panic(err)
+	}
+	writer := bufio.NewWriter(fout)
+	writer.WriteString(header)
+	for i, v := range f {
+		writer.WriteString(fmt.Sprintln(axis[i], v))
+	}
+	writer.Flush()
+	wg.Done()
+}
+
+// 位置ごとの粒子数、平均、標準偏差と温度(keV)を書き出します。buf[位置][運動量]です。
+func writeProfile(position []float32, axis []float32, buf [][]float32, restEnergy float64, header string, fname string, wg *sync.WaitGroup) {
+	fout, err := os.Create(fname)
+	defer fout.Close()
+	if err != nil {
+		panic(err)
+	}
+	writer := bufio.NewWriter(fout)
+	writer.WriteString(header)
+	for i, f := range buf {
+		mean, spread, count := moments(axis, f)
+		writer.WriteString(fmt.Sprintln(position[i], count, mean, spread, temperature(spread, restEnergy)))
+	}
+	writer.Flush()
+	wg.Done()
+}
+
+// 2成分の位相空間から、各成分の平均、標準偏差と温度(keV)を書き出します。
+func writePairMoments(x phaseAxis, y phaseAxis, buf [][]float32, restEnergy float64, header string, fname string, wg *sync.WaitGroup) {
+	fout, err := os.Create(fname)
+	defer fout.Close()
+	if err != nil {
+		panic(err)
+	}
+	writer := bufio.NewWriter(fout)
+	writer.WriteString(header)
+	for _, a := range []struct {
+		axis phaseAxis
+		f    []float32
+	}{{x, marginalFirst(buf)}, {y, marginalSecond(buf)}} {
+		mean, spread, count := moments(a.axis.centres, a.f)
+		writer.WriteString(fmt.Sprintln(a.axis.column.Name, count, mean, spread, temperature(spread, restEnergy)))
+	}
+	writer.Flush()
+	wg.Done()
+}
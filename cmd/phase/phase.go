@@ -7,8 +7,13 @@ import (
 	"os"
 	"sync"
 
+	"github.com/Penpen7/goplot/cmd/decomposition"
 	"github.com/Penpen7/goplot/cmd/fortbin"
+	"github.com/Penpen7/goplot/cmd/physconst"
 	"github.com/Penpen7/goplot/cmd/plotconfig"
+	"github.com/Penpen7/goplot/cmd/plotscript"
+	"github.com/Penpen7/goplot/cmd/render"
+	"github.com/Penpen7/goplot/cmd/runtable"
 	"github.com/Penpen7/goplot/cmd/simulationconfig"
 	"github.com/Penpen7/goplot/cmd/utility"
 )
@@ -30,56 +35,178 @@ func writePhaseSpace(xdata []float32, ydata []float32, vdata [][]float32, fname
 	wg.Done()
 }
 
-func LoadWritePhaseSpace(file *os.File, config simulationconfig.SimulationConfig, plotConfig plotconfig.Art, fileID int, wg *sync.WaitGroup) {
-	momentum_title := [...]string{"pxpy", "pypz", "pzpx"}
-	position_title := [...]string{"xpx", "xpy", "xpz", "ypx", "ypy", "ypz"}
-	velocity_title := [...]string{"vxvy", "vyvz", "vzvx"}
-	position_velocity_title := [...]string{"xvx", "xvy", "xvz", "yvx", "yvy", "yvz"}
+// 位相空間のテキスト出力をスクリプト生成用に登録します。
+func registerScript(title string, fileID int, species int32, xaxis plotscript.Column, yaxis plotscript.Column) {
+	plotscript.Register(plotscript.Kind{
+		Name:    fmt.Sprintf("%s_is=%02d", title, species),
+		Pattern: fmt.Sprintf("%s%%04d_is=%02d.txt", title, species),
+		Format:  plotscript.Map,
+		Slice:   title,
+		Columns: []plotscript.Column{xaxis, yaxis, {Name: "population"}},
+	}, fileID)
+}
 
-	for iparticle := int32(1); iparticle <= config.TotalParticleSpecies; iparticle++ {
-		var dltmomentum float32
-		momentumvsmomentum := []float32{}
-		momentumvsmomentum = make([]float32, config.MomentumMeshNumber*config.MomentumMeshNumber)
-		binary.Read(fortbin.ReadNextChunk(file), binary.LittleEndian, &dltmomentum)
-		momentum := make([]float32, config.MomentumMeshNumber)
-		for i, _ := range momentum {
-			momentum[i] = float32(dltmomentum) * (float32(int32(i)-config.MomentumMeshNumber/2) - 0.5) / float32(config.Particle[iparticle-1].ParticleMass*config.VelocityLight)
-		}
+// 位相空間の出力設定subarts(plotconfig.PhaseSubarts)に指定されたtitleの出力方法を返します。出力しない場合は空です。
+func phaseOutputs(subarts []plotconfig.Subart, title string) []string {
+	subart, found := plotconfig.FindSubart(subarts, title)
+	if !found || !subart.Plot {
+		return nil
+	}
+	return plotconfig.Modes(subart.Center)
+}
 
-		for _, v := range momentum_title {
-			fmt.Printf("\r\033[K loading... %s", v)
-			binary.Read(fortbin.ReadNextChunk(file), binary.LittleEndian, &momentumvsmomentum)
+// 位相空間の軸。valuesはテキスト出力の値(ビン中心または下端)、centresはビン中心、
+// physicalはVTK出力のビン中心(µm, m_e c, c)です。spatialは位置の軸であることを表します。
+type phaseAxis struct {
+	column   plotscript.Column
+	values   []float32
+	centres  []float32
+	physical []float32
+	spatial  bool
+}
 
+// 1つの位相空間をplot.jsonのPhaseに指定された方法で書き出します。
+// txtはテキスト、vtkはVTK ImageData(.pvdの時系列つき)、pngは対数スケールの画像です。
+// marginalは各軸への射影、momentsは位置ごと(2成分の場合は成分ごと)の平均、標準偏差と温度で、
+// momentsを指定すると粒子種ごとの粒子数も記録します。restEnergyは粒子の静止エネルギー(keV)です。
+func writeMap(v string, outputs []string, x phaseAxis, y phaseAxis, buf [][]float32, restEnergy float64, plotConfig plotconfig.Art, fileID int, iparticle int32, simulationTime float32, wg *sync.WaitGroup) {
+	basename := func(suffix string) string {
+		return fmt.Sprintf("%s_%s%04d_is=%02d", v, suffix, fileID, iparticle)
+	}
+	for _, output := range outputs {
+		switch output {
+		case "txt":
 			wg.Add(1)
-			go writePhaseSpace(momentum, momentum, utility.Slice1Dto2D(momentumvsmomentum, config.MomentumMeshNumber, config.MomentumMeshNumber),
+			go writePhaseSpace(x.values, y.values, buf,
 				fmt.Sprintf("%s/%s%04d_is=%02d.txt", plotConfig.OutputASCIIDirectory, v, fileID, iparticle), wg)
-		}
-
-		for titlei, v := range position_title {
-			fmt.Printf("\r\033[K loading... %s", v)
-			positionvsmomentum := make([]float32, config.OutputMeshNumber[titlei/3]*config.MomentumMeshNumber)
-			binary.Read(fortbin.ReadNextChunk(file), binary.LittleEndian, &positionvsmomentum)
-			position := make([]float32, config.OutputMeshNumber[titlei/3])
-			for iposition := int32(0); iposition < config.OutputMeshNumber[titlei/3]; iposition++ {
-				position[iposition] = float32(iposition)
+			registerScript(v, fileID, iparticle, x.column, y.column)
+		case "vtk":
+			fname := fmt.Sprintf("%s/%s%04d_is=%02d.vti", plotConfig.OutputVTKDirectory, v, fileID, iparticle)
+			wg.Add(1)
+			go writePhaseVTK(x.physical, y.physical, buf, v, fname, wg)
+			addSeries(fmt.Sprintf("%s_is=%02d", v, iparticle), simulationTime, fname)
+		case "png":
+			wg.Add(1)
+			go render.WriteHeatmap(buf, render.Options{Log: true, Scale: 2}, fmt.Sprintf("%s/%s%04d_is=%02d.png", plotConfig.OutputPNGDirectory, v, fileID, iparticle), wg)
+		case "marginal":
+			axes := []phaseAxis{y}
+			marginals := [][]float32{marginalSecond(buf)}
+			if !x.spatial {
+				axes = append(axes, x)
+				marginals = append(marginals, marginalFirst(buf))
 			}
+			for i, axis := range axes {
+				wg.Add(1)
+				go writeMarginal(axis.values, marginals[i], fmt.Sprintf("# %s(%s) population : %s\n", axis.column.Name, axis.column.Unit, v),
+					fmt.Sprintf("%s/%s.txt", plotConfig.OutputASCIIDirectory, basename(axis.column.Name)), wg)
+				plotscript.Register(plotscript.Kind{
+					Name:    fmt.Sprintf("%s_%s_is=%02d", v, axis.column.Name, iparticle),
+					Pattern: fmt.Sprintf("%s_%s%%04d_is=%02d.txt", v, axis.column.Name, iparticle),
+					Format:  plotscript.Line,
+					LogY:    true,
+					Columns: []plotscript.Column{axis.column, {Name: "population"}},
+				}, fileID)
+			}
+		case "moments":
+			_, _, count := moments(y.centres, marginalSecond(buf))
+			runtable.Append(plotConfig.OutputASCIIDirectory, fmt.Sprintf("PhaseSpaceTotal_is=%02d", iparticle), fileID,
+				[]plotscript.Column{{Name: "time", Unit: "normalized"}, {Name: "total"}}, []float64{float64(simulationTime), count})
+			fname := fmt.Sprintf("%s/%s.txt", plotConfig.OutputASCIIDirectory, basename("moments"))
 			wg.Add(1)
-			var buf [][]float32
-			if titlei/3 == 1 {
-				buf = utility.Transpy(positionvsmomentum, int(config.OutputMeshNumber[1]), int(config.ParallelNumber), int(config.MomentumMeshNumber))
-			} else {
-				buf = utility.Slice1Dto2D(positionvsmomentum, config.OutputMeshNumber[titlei/3], config.MomentumMeshNumber)
+			if !x.spatial {
+				go writePairMoments(x, y, buf, restEnergy, fmt.Sprintf("# axis total mean(%s) spread(%s) temperature(keV) : %s\n", y.column.Unit, y.column.Unit, v), fname, wg)
+				break
 			}
-			go writePhaseSpace(position, momentum, buf,
-				fmt.Sprintf("%s/%s%04d_is=%02d.txt", plotConfig.OutputASCIIDirectory, v, fileID, iparticle), wg)
+			go writeProfile(x.values, y.centres, buf, restEnergy, fmt.Sprintf("# %s(%s) total mean(%s) spread(%s) temperature(keV) : %s\n", x.column.Name, x.column.Unit, y.column.Unit, y.column.Unit, v), fname, wg)
+			plotscript.Register(plotscript.Kind{
+				Name:    fmt.Sprintf("%s_moments_is=%02d", v, iparticle),
+				Pattern: fmt.Sprintf("%s_moments%%04d_is=%02d.txt", v, iparticle),
+				Format:  plotscript.Line,
+				Columns: []plotscript.Column{x.column, {Name: "total"}, {Name: "mean", Unit: y.column.Unit}, {Name: "spread", Unit: y.column.Unit}, {Name: "temperature", Unit: "keV"}},
+			}, fileID)
+		default:
+			fmt.Println("Warning:invalid phase output:", v, output)
+		}
+	}
+}
+
+// 位相空間の1つのブロックを読み込み、書き出します。
+// 最初のレコードはビン幅で、続いて2成分の分布(pairTitle)と位置との分布(positionTitle)が並びます。
+// 軸の値はビン幅にscaleをかけたもので、unitはその単位です。VTKではさらにphysicalScaleをかけます。
+// 出力するかどうかは粒子種ごとの指定に従い、出力ファイルには粒子種の名前をつけます(Electron_pxpyなど)。
+func loadWriteBlock(file *os.File, config simulationconfig.SimulationConfig, plotConfig plotconfig.Art, fileID int, iparticle int32, simulationTime float32,
+	pairTitle []string, positionTitle []string, scale float32, unit string, physicalScale float32, restEnergy float64, wg *sync.WaitGroup) {
+	var dlt float32
+	binary.Read(fortbin.ReadNextChunk(file), binary.LittleEndian, &dlt)
+	edges, centres := binAxis(float64(dlt*scale), int(config.MomentumMeshNumber))
+	values := make([]float32, config.MomentumMeshNumber)
+	binCentres := make([]float32, config.MomentumMeshNumber)
+	physical := make([]float32, config.MomentumMeshNumber)
+	for i := range values {
+		binCentres[i] = float32(centres[i])
+		physical[i] = float32(centres[i]) * physicalScale
+		values[i] = float32(centres[i])
+		if plotConfig.PhaseAxis == "edge" {
+			values[i] = float32(edges[i])
+		}
+	}
+	subarts := plotconfig.PhaseSubarts(plotConfig, config, iparticle)
+	speciesName := plotconfig.SpeciesName(plotConfig, config, iparticle)
+	axis := func(name string) phaseAxis {
+		return phaseAxis{plotscript.Column{Name: name, Unit: unit}, values, binCentres, physical, false}
+	}
+
+	for _, v := range pairTitle {
+		outputs := phaseOutputs(subarts, v)
+		if len(outputs) == 0 {
+			fortbin.SkipNextChunk(file)
+			continue
 		}
+		fmt.Printf("\r\033[K loading... %s", v)
+		pair := make([]float32, config.MomentumMeshNumber*config.MomentumMeshNumber)
+		binary.Read(fortbin.ReadNextChunk(file), binary.LittleEndian, &pair)
+		buf := utility.Slice1Dto2D(pair, config.MomentumMeshNumber, config.MomentumMeshNumber)
+		writeMap(speciesName+"_"+v, outputs, axis(v[:2]), axis(v[2:]), buf, restEnergy, plotConfig, fileID, iparticle, simulationTime, wg)
+	}
 
-		fortbin.ReadNextChunk(file)
-		for i := int32(0); i < int32(len(velocity_title)); i++ {
-			fortbin.ReadNextChunk(file)
+	for titlei, v := range positionTitle {
+		outputs := phaseOutputs(subarts, v)
+		if len(outputs) == 0 {
+			fortbin.SkipNextChunk(file)
+			continue
+		}
+		fmt.Printf("\r\033[K loading... %s", v)
+		positionvsaxis := make([]float32, config.OutputMeshNumber[titlei/3]*config.MomentumMeshNumber)
+		binary.Read(fortbin.ReadNextChunk(file), binary.LittleEndian, &positionvsaxis)
+		position := phaseAxis{column: plotscript.Column{Name: v[:1], Unit: "grid"}, spatial: true}
+		for iposition := int32(0); iposition < config.OutputMeshNumber[titlei/3]; iposition++ {
+			position.values = append(position.values, float32(iposition))
+			position.centres = append(position.centres, float32(iposition))
+			position.physical = append(position.physical, float32(iposition)*physconst.OutputMeshSpacing[titlei/3])
 		}
-		for i := int32(0); i < int32(len(position_velocity_title)); i++ {
-			fortbin.ReadNextChunk(file)
+		// プロセスごとに位置の方向に分かれたブロックを全体の順に並べ替える
+		positionvsaxis, err := decomposition.Reorder(positionvsaxis, []int{int(config.OutputMeshNumber[titlei/3]), int(config.MomentumMeshNumber)}, []int{titlei / 3, -1})
+		if err != nil {
+			fmt.Printf("\x1b[35mwarning : %sを並べ替えられませんでした : %s\x1b[0m\n", v, err)
 		}
+		buf := utility.Slice1Dto2D(positionvsaxis, config.OutputMeshNumber[titlei/3], config.MomentumMeshNumber)
+		writeMap(speciesName+"_"+v, outputs, position, axis(v[1:]), buf, restEnergy, plotConfig, fileID, iparticle, simulationTime, wg)
+	}
+}
+
+func LoadWritePhaseSpace(file *os.File, config simulationconfig.SimulationConfig, plotConfig plotconfig.Art, fileID int, simulationTime float32, wg *sync.WaitGroup) {
+	momentum_title := []string{"pxpy", "pypz", "pzpx"}
+	position_title := []string{"xpx", "xpy", "xpz", "ypx", "ypy", "ypz"}
+	velocity_title := []string{"vxvy", "vyvz", "vzvx"}
+	position_velocity_title := []string{"xvx", "xvy", "xvz", "yvx", "yvy", "yvz"}
+
+	for iparticle := int32(1); iparticle <= config.TotalParticleSpecies; iparticle++ {
+		// 運動量はp/mc、速度はv/cで出力する。VTKでは運動量をm_e c単位にする
+		mass := config.Particle[iparticle-1].ParticleMass
+		restEnergy := mass * physconst.ElectronRestEnergy
+		loadWriteBlock(file, config, plotConfig, fileID, iparticle, simulationTime, momentum_title, position_title,
+			float32(1/(mass*config.VelocityLight)), "p/mc", float32(mass), restEnergy, wg)
+		loadWriteBlock(file, config, plotConfig, fileID, iparticle, simulationTime, velocity_title, position_velocity_title,
+			float32(1/config.VelocityLight), "v/c", 1, restEnergy, wg)
 	}
 }
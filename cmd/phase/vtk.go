@@ -0,0 +1,100 @@
+package phase
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+)
+
+// .pvdに並べる1ステップ分のファイル
+type seriesEntry struct {
+	time  float32
+	fname string
+}
+
+var (
+	seriesMutex sync.Mutex
+	series      = map[string][]seriesEntry{}
+)
+
+// 位相空間を2次元のVTK ImageDataで書き出します。軸は等間隔であるとして、
+// 原点と格子間隔をxaxis, yaxisの最初の2点から求めます。
+func writePhaseVTK(xaxis []float32, yaxis []float32, data [][]float32, arrayName string, fname string, wg *sync.WaitGroup) {
+	fout, err := os.Create(fname)
+	defer fout.Close()
+	if err != nil {
+		panic(err)
+	}
+	spacing := func(axis []float32) float32 {
+		if len(axis) < 2 {
+			return 1
+		}
+		return axis[1] - axis[0]
+	}
+	nx, ny := len(xaxis), len(yaxis)
+	writer := bufio.NewWriter(fout)
+	writer.WriteString("<?xml version=\"1.0\"?>\n")
+	writer.WriteString("<VTKFile type=\"ImageData\" byte_order=\"LittleEndian\">")
+	writer.WriteString(fmt.Sprintf("<ImageData WholeExtent=\"0 %d 0 %d 0 0\" Origin=\"%g %g 0\" Spacing=\"%g %g 1\">", nx-1, ny-1, xaxis[0], yaxis[0], spacing(xaxis), spacing(yaxis)))
+	writer.WriteString(fmt.Sprintf("<Piece Extent=\"0 %d 0 %d 0 0\">", nx-1, ny-1))
+	writer.WriteString(fmt.Sprintf("<PointData Scalars=\"%s\">", arrayName))
+	writer.WriteString(fmt.Sprintf("<DataArray Name=\"%s\" type=\"Float32\" format=\"binary\">", arrayName))
+
+	header := bytes.NewBuffer(nil)
+	binary.Write(header, binary.LittleEndian, int32(nx*ny*4))
+	writer.WriteString(base64.StdEncoding.EncodeToString(header.Bytes()))
+	// VTKはx方向が最も速く変わる順に並べる
+	buf := bytes.NewBuffer(nil)
+	for j := 0; j < ny; j++ {
+		for i := 0; i < nx; i++ {
+			binary.Write(buf, binary.LittleEndian, data[i][j])
+		}
+	}
+	writer.WriteString(base64.StdEncoding.EncodeToString(buf.Bytes()))
+
+	writer.WriteString("</DataArray></PointData></Piece></ImageData></VTKFile>")
+	writer.Flush()
+	wg.Done()
+}
+
+// VTKファイルを.pvdの時系列に加えます。
+func addSeries(name string, time float32, fname string) {
+	seriesMutex.Lock()
+	defer seriesMutex.Unlock()
+	series[name] = append(series[name], seriesEntry{time, filepath.Base(fname)})
+}
+
+// 位相空間ごとに、書き出したVTKファイルを時刻順に並べた.pvdをdirに書き出します。
+func WriteSeries(dir string) error {
+	seriesMutex.Lock()
+	defer seriesMutex.Unlock()
+	names := []string{}
+	for name := range series {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		entries := series[name]
+		sort.SliceStable(entries, func(i, j int) bool { return entries[i].time < entries[j].time })
+		fout, err := os.Create(fmt.Sprintf("%s/%s.pvd", dir, name))
+		if err != nil {
+			return err
+		}
+		writer := bufio.NewWriter(fout)
+		writer.WriteString("<?xml version=\"1.0\"?>\n")
+		writer.WriteString("<VTKFile type=\"Collection\" version=\"0.1\" byte_order=\"LittleEndian\">\n<Collection>\n")
+		for _, e := range entries {
+			writer.WriteString(fmt.Sprintf("<DataSet timestep=\"%g\" part=\"0\" file=\"%s\"/>\n", e.time, e.fname))
+		}
+		writer.WriteString("</Collection>\n</VTKFile>\n")
+		writer.Flush()
+		fout.Close()
+	}
+	return nil
+}
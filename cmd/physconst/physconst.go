@@ -1,16 +1,35 @@
 package physconst
 
 import (
+	"fmt"
 	"math"
 
 	"github.com/Penpen7/goplot/cmd/simulationconfig"
 )
 
+// 電子の静止エネルギー(keV)
+const ElectronRestEnergy = 511.0
+
 var ElectricFieldNormalizeConstant float32
 var MagneticFieldNormalizeConstant float32
 var NormalizedEnergy float32
 
-func CalculateNormalizeConstant(sc simulationconfig.SimulationConfig) {
+// 出力メッシュの格子間隔(µm)
+var OutputMeshSpacing [3]float32
+
+// レーザーの波長(µm)。gfin.datのLambdaをwavelengthUnitに従って換算した値です。
+var LaserWavelength float32
+
+// 密度の規格化定数(cm^-3)
+var NormalizedNumberDensity float32
+
+// レーザーの臨界密度(cm^-3)。波長が分からなければ0です。
+var CriticalDensity float32
+
+// 規格化定数を求めます。wavelengthUnitはgfin.datのLambdaの単位で、
+// um(µm)、cm、normalized(長さの規格化単位)のいずれかです。
+// 単位が正しくなければ波長を不明(0)とし、エラーを返します。
+func CalculateNormalizeConstant(sc simulationconfig.SimulationConfig, wavelengthUnit string) error {
 	lightSpeed := 2.99792458e+10     //c_r
 	electronMass := 9.10938356e-28   //rme_r
 	electricUnit := 4.8032e-10       //e_r
@@ -21,4 +40,31 @@ func CalculateNormalizeConstant(sc simulationconfig.SimulationConfig) {
 	ElectricFieldNormalizeConstant = float32(4.0 * math.Pi * normalizedNumberDensity * electricUnit * normalizedDeltaX * 1e+4 * 3.0)
 	MagneticFieldNormalizeConstant = ElectricFieldNormalizeConstant / float32(lightSpeed*1e-2)
 	NormalizedEnergy = float32(4.0 * math.Pi * normalizedNumberDensity * electricUnit * electricUnit * normalizedDeltaX * normalizedDeltaX / electronVoltToJoule)
+	NormalizedNumberDensity = float32(normalizedNumberDensity)
+	for i := 0; i < 3; i++ {
+		if sc.OutputMeshNumber[i] > 0 {
+			OutputMeshSpacing[i] = float32(sc.SystemL[i] / float64(sc.OutputMeshNumber[i]) * normalizedDeltaX * 1e+4)
+		}
+	}
+
+	// 波長をµmに換算する
+	var wavelength float64
+	switch wavelengthUnit {
+	case "um":
+		wavelength = sc.Laser.Lambda
+	case "cm":
+		wavelength = sc.Laser.Lambda * 1e+4
+	case "normalized":
+		wavelength = sc.Laser.Lambda * normalizedDeltaX * 1e+4
+	default:
+		LaserWavelength, CriticalDensity = 0, 0
+		return fmt.Errorf("invalid wavelength unit: %s", wavelengthUnit)
+	}
+	LaserWavelength, CriticalDensity = 0, 0
+	if wavelength > 0 {
+		LaserWavelength = float32(wavelength)
+		// n_c = 1.115e21 / λ(µm)^2 cm^-3
+		CriticalDensity = float32(1.115e+21 / (wavelength * wavelength))
+	}
+	return nil
 }
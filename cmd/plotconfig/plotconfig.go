@@ -6,7 +6,10 @@ import (
 	"fmt"
 	"io/ioutil"
 	"os"
+	"strconv"
 	"strings"
+
+	"github.com/Penpen7/goplot/cmd/simulationconfig"
 )
 
 type Subart struct {
@@ -14,13 +17,53 @@ type Subart struct {
 	Plot   bool
 	Center string
 }
+
+// 時系列を記録する点。Positionは"x,y,z"で、格子番号、mid、0.25L、3.5umが使えます。
+// Quantitiesは記録する物理量をスペース区切りで指定します(Ex, Emag, Ion_Density_is=01, ChargeDensityなど)。
+type Probe struct {
+	Name       string
+	Plot       bool
+	Position   string
+	Quantities string
+}
+
+// 粒子種ごとの出力設定。Targetは粒子種の番号(1, 2, ...)かgfin.datのAtomの名前です。
+// Labelを指定すると、ファイル名などに粒子種の名前として使います。
+// ParticleのNameはDensity, Energy, EnergyFlux_x, EnergyFlux_y, Temperature, Energy_Distribution,
+// Energy_DistributionLogLogです。Phaseを指定すると、その粒子種にはArtのPhaseの代わりに使います。
+type Species struct {
+	Target   string
+	Label    string
+	Plot     bool
+	Particle []Subart
+	Phase    []Subart
+}
 type Art struct {
 	OutputASCIIDirectory string
 	OutputVTKDirectory   string
+	OutputSVGDirectory   string
+	OutputNPYDirectory   string
+	OutputPNGDirectory   string
+	FieldBoundary        string
+	FieldStaggering      string
+	EnergyDriftThreshold float64
+	DensityUnit          string
+	LaserWavelengthUnit  string
+	PhaseAxis            string
+	Decomposition        string
+	CutoffCount          float64
+	AboveEnergies        string
+	LaserEnergy          float64
 	Field                []Subart
 	Particle             []Subart
+	Species              []Species
 	Phase                []Subart
 	EnergyDistribution   []Subart
+	EnergyFit            []Subart
+	Rebin                []Subart
+	Spectrum             []Subart
+	Streak               []Subart
+	Probe                []Probe
 }
 
 func LoadPlotConfig(v *Art, plotConfigFileName string) {
@@ -28,7 +71,26 @@ func LoadPlotConfig(v *Art, plotConfigFileName string) {
 		fmt.Printf("\x1b[35mwarning : %sが存在しないため、新規作成しました。プロットしたいデータを変える場合、%sを変更してください。\n", plotConfigFileName, plotConfigFileName)
 		fmt.Printf("Name:データの名前\n")
 		fmt.Printf("Plot:出力するか否か\n")
-		fmt.Printf("Center:どのデータをプロットするか(複数ある場合はスペース区切りで指定)\x1b[0m\n")
+		fmt.Printf("Center:どのデータをプロットするか(複数ある場合はスペース区切りで指定)\n")
+		fmt.Printf("        xy@z=12, x@y=0.25L,z=mid, xy@z=2um, xy@z=10:20avg, xy@z=maxのように断面の位置や平均・最大値を指定できます。\n")
+		fmt.Printf("        line@0,mid,mid:63,mid,mid:n=200のように頂点と標本点の数を指定すると、折れ線に沿った値を出力します。\n")
+		fmt.Printf("        avg@y=8bands,z=mid, avg@y=0:15+16:31, avg@x,y,zのように平均する軸と帯を指定すると、帯ごとの平均を出力します。\n")
+		fmt.Printf("        xaverage, zxaverage, whole_averageはそれぞれavg@y=8bands,z=mid, avg@y, avg@x,y,zと同じです。\n")
+		fmt.Printf("        svgを加えると1次元のデータをSVGでも出力します。logx, logyで対数軸になります。\n")
+		fmt.Printf("        npy, pngを加えると断面をNumPy形式、PNG画像でも出力します。\n")
+		fmt.Printf("FieldBoundary, FieldStaggering:差分演算の境界条件(auto, periodic, open)と格子です。出力メッシュがYee格子であることを確かめた場合のみFieldStaggeringをyeeにしてください。既定のcollocatedでは全成分が同じ格子点にあるとして中心差分を使います。\n")
+		fmt.Printf("Phase:pxpy, xpx, vxvy, xvxなどの位相空間ごとに出力方法(txt, vtk, png, marginal, moments)を指定します。Plotがfalseの位相空間は読み飛ばします。\n")
+		fmt.Printf("EnergyDistribution:Energy_Recordsで粒子種ごとのエネルギー分布の後に続く12個のレコードを解釈せずにそのまま出力します。中身はまだ確かめていません。Accelerationでカットオフエネルギーと変換効率の時系列を出力します。\n")
+		fmt.Printf("EnergyFit:エネルギー分布ごとにフィットするモデル(exp, maxwell, juttner, maxwell2)と範囲(window=10keV:1MeV)を指定します。温度の時系列と、フィットした曲線を出力します。\n")
+		fmt.Printf("Rebin:エネルギー分布ごとに、bins=log:1keV:100MeV:50やbins=lin:0:20MeV:100のビンへの分け直し、unit=MeVの出力単位、cumulativeでN(>E)、average=5で最近の5ステップの平均を指定します。bins=を省くと最初のステップのビンを使うため、対数ビンの分布を平均するときはbins=が必要です。\n")
+		fmt.Printf("CutoffCount, AboveEnergies, LaserEnergy:EnergyDistributionのAccelerationで、カットオフエネルギーとみなす粒子数、粒子数を数えるエネルギー(1MeV 10MeVのようにスペース区切り)、変換効率の基準にするレーザーのエネルギー(DiagnosticsのFieldEnergyと同じ規格化単位)を指定します。LaserEnergyが0ならgfin.datのE0, Tau0, Dy0から平坦なパルスとして見積もります。\n")
+		fmt.Printf("Decomposition:領域分割の配置です。autoはy方向にParallelNumber個、x=2,y=4のように各軸のプロセス数も指定できます。\n")
+		fmt.Printf("PhaseAxis:位相空間のテキスト出力の運動量・速度軸を、ビンの中心(center)か下端(edge)で出力します。\n")
+		fmt.Printf("LaserWavelengthUnit:gfin.datのLambdaの単位です。um, cm, normalized(長さの規格化単位)が指定できます。k/k0の軸と臨界密度に使います。\n")
+		fmt.Printf("DensityUnit:密度の出力単位です。raw(規格化単位), nc(臨界密度), cm-3が指定できます。場の出力、プローブ、時空間図のすべてに使います。\n")
+		fmt.Printf("Species:粒子種の番号かAtomの名前(Target)ごとに出力を指定します。指定した粒子種にはParticleのIon_*, Electron_*の指定は使われません。Phaseを指定すると位相空間の出力もその粒子種だけ変えられます。\n")
+		fmt.Printf("Spectrum:kx, ky, kzで1次元、kxky, kykz, kzkxで2次元の空間スペクトルを出力します。hann, hammingで窓関数をかけます。\n")
+		fmt.Printf("Streak:x, y, z, line@のいずれかの切り出しを全ステップについて並べ、位置と時刻の2次元データを最後に出力します。\x1b[0m\n")
 
 		file, _ := os.Create(plotConfigFileName)
 		var buf2 bytes.Buffer
@@ -43,33 +105,115 @@ func LoadPlotConfig(v *Art, plotConfigFileName string) {
 	if err != nil {
 		fmt.Println(err)
 	}
+	if v.Phase == nil {
+		fmt.Printf("\x1b[35mwarning : %sにPhaseの指定がないため、以前と同じく運動量の位相空間(pxpy, pypz, pzpx, xpx, xpy, xpz, ypx, ypy, ypz)をすべて出力します。\x1b[0m\n", plotConfigFileName)
+		v.Phase = NewArt().Phase
+	}
+	if v.PhaseAxis != "center" && v.PhaseAxis != "edge" {
+		fmt.Printf("\x1b[35mwarning : %sのPhaseAxis(%s)はcenterかedgeで指定してください。centerとして扱います。\x1b[0m\n", plotConfigFileName, v.PhaseAxis)
+		v.PhaseAxis = "center"
+	}
 	return
 }
 func NewArt() *Art {
 	var tempart Art
-	tempart.Field = append(tempart.Field, Subart{"Ex", true, "xy x y"})
-	tempart.Field = append(tempart.Field, Subart{"Ey", true, "xy x y"})
-	tempart.Field = append(tempart.Field, Subart{"Ez", false, "xy x y"})
-	tempart.Field = append(tempart.Field, Subart{"Bx", false, "xy x y"})
-	tempart.Field = append(tempart.Field, Subart{"By", false, "xy x y"})
-	tempart.Field = append(tempart.Field, Subart{"Bz", true, "xy x y"})
-	tempart.Field = append(tempart.Field, Subart{"Jx", true, "xy x y"})
-	tempart.Field = append(tempart.Field, Subart{"Jy", true, "xy x y"})
-	tempart.Field = append(tempart.Field, Subart{"Jz", false, "xy x y"})
-	tempart.Particle = append(tempart.Particle, Subart{"Ion_Density", true, "xy x y"})
-	tempart.Particle = append(tempart.Particle, Subart{"Ion_Energy", false, "xy x y"})
-	tempart.Particle = append(tempart.Particle, Subart{"Ion_Energy_Distribution", true, ""})
-	tempart.Particle = append(tempart.Particle, Subart{"Ion_Energy_DistributionLogLog", true, ""})
-	tempart.Particle = append(tempart.Particle, Subart{"Ion_EnergyFlux_x", false, "xy x y"})
-	tempart.Particle = append(tempart.Particle, Subart{"Ion_EnergyFlux_y", false, "xy x y"})
-	tempart.Particle = append(tempart.Particle, Subart{"Electron_Density", true, "xy x y"})
-	tempart.Particle = append(tempart.Particle, Subart{"Electron_Energy", false, "xy x y"})
-	tempart.Particle = append(tempart.Particle, Subart{"Electron_Energy_Distribution", true, ""})
-	tempart.Particle = append(tempart.Particle, Subart{"Electron_Energy_DistributionLogLog", true, ""})
-	tempart.Particle = append(tempart.Particle, Subart{"Electron_EnergyFlux_x", false, "xy x y"})
-	tempart.Particle = append(tempart.Particle, Subart{"Electron_EnergyFlux_y", false, "xy x y"})
+	tempart.Field = append(tempart.Field, Subart{"Ex", true, "xy x y svg"})
+	tempart.Field = append(tempart.Field, Subart{"Ey", true, "xy x y svg"})
+	tempart.Field = append(tempart.Field, Subart{"Ez", false, "xy x y svg"})
+	tempart.Field = append(tempart.Field, Subart{"Bx", false, "xy x y svg"})
+	tempart.Field = append(tempart.Field, Subart{"By", false, "xy x y svg"})
+	tempart.Field = append(tempart.Field, Subart{"Bz", true, "xy x y svg"})
+	tempart.Field = append(tempart.Field, Subart{"Jx", true, "xy x y svg"})
+	tempart.Field = append(tempart.Field, Subart{"Jy", true, "xy x y svg"})
+	tempart.Field = append(tempart.Field, Subart{"Jz", false, "xy x y svg"})
+	tempart.Field = append(tempart.Field, Subart{"Emag", false, "xy x y svg"})
+	tempart.Field = append(tempart.Field, Subart{"Bmag", false, "xy x y svg"})
+	tempart.Field = append(tempart.Field, Subart{"EnergyDensity", false, "xy x y svg"})
+	tempart.Field = append(tempart.Field, Subart{"Sx", false, "xy x y svg"})
+	tempart.Field = append(tempart.Field, Subart{"Sy", false, "xy x y svg"})
+	tempart.Field = append(tempart.Field, Subart{"Sz", false, "xy x y svg"})
+	tempart.Field = append(tempart.Field, Subart{"EdotJ", false, "xy x y svg"})
+	tempart.Field = append(tempart.Field, Subart{"divE", false, "xy x y svg"})
+	tempart.Field = append(tempart.Field, Subart{"divB", false, "xy x y svg"})
+	tempart.Field = append(tempart.Field, Subart{"curlEx", false, "xy x y svg"})
+	tempart.Field = append(tempart.Field, Subart{"curlEy", false, "xy x y svg"})
+	tempart.Field = append(tempart.Field, Subart{"curlEz", false, "xy x y svg"})
+	tempart.Field = append(tempart.Field, Subart{"curlBx", false, "xy x y svg"})
+	tempart.Field = append(tempart.Field, Subart{"curlBy", false, "xy x y svg"})
+	tempart.Field = append(tempart.Field, Subart{"curlBz", false, "xy x y svg"})
+	tempart.Field = append(tempart.Field, Subart{"CurlBminusJx", false, "xy x y svg"})
+	tempart.Field = append(tempart.Field, Subart{"CurlBminusJy", false, "xy x y svg"})
+	tempart.Field = append(tempart.Field, Subart{"CurlBminusJz", false, "xy x y svg"})
+	tempart.Field = append(tempart.Field, Subart{"GaussResidual", false, "xy x y svg"})
+	tempart.Field = append(tempart.Field, Subart{"gradRhox", false, "xy x y svg"})
+	tempart.Field = append(tempart.Field, Subart{"gradRhoy", false, "xy x y svg"})
+	tempart.Field = append(tempart.Field, Subart{"gradRhoz", false, "xy x y svg"})
+	tempart.Field = append(tempart.Field, Subart{"GaussLaw", true, ""})
+	tempart.Field = append(tempart.Field, Subart{"Diagnostics", false, ""})
+	tempart.Particle = append(tempart.Particle, Subart{"Ion_Density", true, "xy x y svg"})
+	tempart.Particle = append(tempart.Particle, Subart{"Ion_Energy", false, "xy x y svg"})
+	tempart.Particle = append(tempart.Particle, Subart{"Ion_Energy_Distribution", true, "svg logy"})
+	tempart.Particle = append(tempart.Particle, Subart{"Ion_Energy_DistributionLogLog", true, "svg logx logy"})
+	tempart.Particle = append(tempart.Particle, Subart{"Ion_EnergyFlux_x", false, "xy x y svg"})
+	tempart.Particle = append(tempart.Particle, Subart{"Ion_EnergyFlux_y", false, "xy x y svg"})
+	tempart.Particle = append(tempart.Particle, Subart{"Electron_Density", true, "xy x y svg"})
+	tempart.Particle = append(tempart.Particle, Subart{"Electron_Energy", false, "xy x y svg"})
+	tempart.Particle = append(tempart.Particle, Subart{"Electron_Energy_Distribution", true, "svg logy"})
+	tempart.Particle = append(tempart.Particle, Subart{"Electron_Energy_DistributionLogLog", true, "svg logx logy"})
+	tempart.Particle = append(tempart.Particle, Subart{"Electron_EnergyFlux_x", false, "xy x y svg"})
+	tempart.Particle = append(tempart.Particle, Subart{"Electron_EnergyFlux_y", false, "xy x y svg"})
+	tempart.Particle = append(tempart.Particle, Subart{"Ion_Temperature", false, "xy x y svg"})
+	tempart.Particle = append(tempart.Particle, Subart{"Electron_Temperature", false, "xy x y svg"})
+	tempart.Particle = append(tempart.Particle, Subart{"ChargeDensity", false, "xy x y svg"})
+	tempart.Particle = append(tempart.Particle, Subart{"IonDensity", false, "xy x y svg"})
+	tempart.Particle = append(tempart.Particle, Subart{"ElectronDensity", false, "xy x y svg"})
+	tempart.Particle = append(tempart.Particle, Subart{"MeanZ", false, "xy x y svg"})
+	tempart.EnergyDistribution = append(tempart.EnergyDistribution, Subart{"Energy_Records", false, ""})
+	tempart.EnergyDistribution = append(tempart.EnergyDistribution, Subart{"Acceleration", false, ""})
+	tempart.EnergyFit = append(tempart.EnergyFit, Subart{"Ion_Energy_Distribution", false, "maxwell exp window=all"})
+	tempart.EnergyFit = append(tempart.EnergyFit, Subart{"Ion_Energy_DistributionLog", false, "maxwell2 exp window=all"})
+	tempart.EnergyFit = append(tempart.EnergyFit, Subart{"Electron_Energy_Distribution", false, "maxwell juttner exp window=all"})
+	tempart.EnergyFit = append(tempart.EnergyFit, Subart{"Electron_Energy_DistributionLog", false, "juttner maxwell2 exp window=all"})
+	tempart.Rebin = append(tempart.Rebin, Subart{"Ion_Energy_DistributionLog", false, "bins=log:10keV:100MeV:50 unit=MeV cumulative average=3"})
+	tempart.Rebin = append(tempart.Rebin, Subart{"Electron_Energy_DistributionLog", false, "bins=log:1keV:100MeV:50 unit=MeV cumulative average=3"})
+	tempart.Phase = append(tempart.Phase, Subart{"pxpy", true, "txt"})
+	tempart.Phase = append(tempart.Phase, Subart{"pypz", true, "txt"})
+	tempart.Phase = append(tempart.Phase, Subart{"pzpx", true, "txt"})
+	tempart.Phase = append(tempart.Phase, Subart{"xpx", true, "txt"})
+	tempart.Phase = append(tempart.Phase, Subart{"xpy", true, "txt"})
+	tempart.Phase = append(tempart.Phase, Subart{"xpz", true, "txt"})
+	tempart.Phase = append(tempart.Phase, Subart{"ypx", true, "txt"})
+	tempart.Phase = append(tempart.Phase, Subart{"ypy", true, "txt"})
+	tempart.Phase = append(tempart.Phase, Subart{"ypz", true, "txt"})
+	tempart.Phase = append(tempart.Phase, Subart{"vxvy", false, "txt"})
+	tempart.Phase = append(tempart.Phase, Subart{"vyvz", false, "txt"})
+	tempart.Phase = append(tempart.Phase, Subart{"vzvx", false, "txt"})
+	tempart.Phase = append(tempart.Phase, Subart{"xvx", false, "txt"})
+	tempart.Phase = append(tempart.Phase, Subart{"xvy", false, "txt"})
+	tempart.Phase = append(tempart.Phase, Subart{"xvz", false, "txt"})
+	tempart.Phase = append(tempart.Phase, Subart{"yvx", false, "txt"})
+	tempart.Phase = append(tempart.Phase, Subart{"yvy", false, "txt"})
+	tempart.Phase = append(tempart.Phase, Subart{"yvz", false, "txt"})
+	tempart.Species = []Species{}
 	tempart.OutputASCIIDirectory = "biny_dataASCII"
 	tempart.OutputVTKDirectory = "biny_dataVTK"
+	tempart.OutputSVGDirectory = "biny_dataSVG"
+	tempart.OutputNPYDirectory = "biny_dataNPY"
+	tempart.OutputPNGDirectory = "biny_dataPNG"
+	tempart.Spectrum = append(tempart.Spectrum, Subart{"Ey", false, "kx ky kxky hann png"})
+	tempart.Spectrum = append(tempart.Spectrum, Subart{"Bz", false, "kx ky kxky hann png"})
+	tempart.Streak = append(tempart.Streak, Subart{"Ey", false, "x npy png"})
+	tempart.Streak = append(tempart.Streak, Subart{"Electron_Density_is=02", false, "x npy png"})
+	tempart.Probe = append(tempart.Probe, Probe{"center", false, "mid,mid,mid", "Ex Ey Bz Emag"})
+	tempart.FieldBoundary = "auto"
+	tempart.FieldStaggering = "collocated"
+	tempart.EnergyDriftThreshold = 0.05
+	tempart.DensityUnit = "raw"
+	tempart.LaserWavelengthUnit = "um"
+	tempart.PhaseAxis = "center"
+	tempart.Decomposition = "auto"
+	tempart.CutoffCount = 10
+	tempart.AboveEnergies = "1MeV 10MeV"
 	return &tempart
 }
 func SearchSubart(subart []Subart, name string) bool {
@@ -80,10 +224,118 @@ func SearchSubart(subart []Subart, name string) bool {
 	}
 	return false
 }
+
+// 名前が一致するSubartを返します。
+func FindSubart(subart []Subart, name string) (Subart, bool) {
+	for _, v := range subart {
+		if v.Name == name {
+			return v, true
+		}
+	}
+	return Subart{}, false
+}
+
+// 粒子種species(1から数えた番号)に対応するSpeciesの指定を返します。
+func FindSpecies(art Art, config simulationconfig.SimulationConfig, species int32) (Species, bool) {
+	atom := config.Particle[species-1].Atom
+	for _, v := range art.Species {
+		if v.Target == strconv.Itoa(int(species)) || (atom != "" && v.Target == atom) {
+			return v, true
+		}
+	}
+	return Species{}, false
+}
+
+// ファイル名に使う粒子種の名前を返します。
+// SpeciesのLabel、gfin.datのAtom、Ion/Electronの順に使います。
+func SpeciesName(art Art, config simulationconfig.SimulationConfig, species int32) string {
+	if v, found := FindSpecies(art, config, species); found && v.Label != "" {
+		return v.Label
+	}
+	if atom := config.Particle[species-1].Atom; atom != "" {
+		return atom
+	}
+	return SpeciesKind(config, species)
+}
+
+// 粒子種がイオンならIon、電子ならElectronを返します。ParticleのIon_*, Electron_*の指定に使います。
+func SpeciesKind(config simulationconfig.SimulationConfig, species int32) string {
+	if species <= config.IonNumber {
+		return "Ion"
+	}
+	return "Electron"
+}
+
+// 粒子種speciesの粒子の出力設定を返します。Nameは物理量の名前(Density, Energy_Distributionなど)です。
+// Speciesに指定があればそれを、なければParticleのIon_*, Electron_*の指定を使います。
+func ParticleSubarts(art Art, config simulationconfig.SimulationConfig, species int32) []Subart {
+	if v, found := FindSpecies(art, config, species); found {
+		if !v.Plot {
+			return nil
+		}
+		return v.Particle
+	}
+	prefix := SpeciesKind(config, species) + "_"
+	subarts := []Subart{}
+	for _, v := range art.Particle {
+		if quantity, found := strings.CutPrefix(v.Name, prefix); found {
+			subarts = append(subarts, Subart{Name: quantity, Plot: v.Plot, Center: v.Center})
+		}
+	}
+	return subarts
+}
+
+// 粒子種speciesの位相空間の出力設定を返します。
+// SpeciesのPlotがfalseなら空、SpeciesにPhaseがあればそれを、なければPhaseを使います。
+func PhaseSubarts(art Art, config simulationconfig.SimulationConfig, species int32) []Subart {
+	if v, found := FindSpecies(art, config, species); found {
+		if !v.Plot {
+			return nil
+		}
+		if v.Phase != nil {
+			return v.Phase
+		}
+	}
+	return art.Phase
+}
+
+// Centerに指定できる出力方法以外のオプション
+var centerFlags = map[string]bool{"svg": true, "logx": true, "logy": true, "npy": true, "png": true, "hann": true, "hamming": true}
+
+// Centerからオプションを除いた出力モードを返します。
+func Modes(center string) []string {
+	modes := []string{}
+	for _, v := range strings.Fields(center) {
+		if !centerFlags[v] {
+			modes = append(modes, v)
+		}
+	}
+	return modes
+}
+
+// Centerにオプションが指定されているかを返します。
+func HasFlag(center string, flag string) bool {
+	for _, v := range strings.Fields(center) {
+		if v == flag {
+			return true
+		}
+	}
+	return false
+}
+
 func ShowPlotConfig(config Art) {
 	fmt.Println("")
 	fmt.Printf("出力先のディレクトリ(テキストファイル) : %s\n", config.OutputASCIIDirectory)
 	fmt.Printf("出力先のディレクトリ(VTKファイル))     : %s\n", config.OutputVTKDirectory)
+	fmt.Printf("出力先のディレクトリ(SVGファイル)      : %s\n", config.OutputSVGDirectory)
+	fmt.Printf("出力先のディレクトリ(NPYファイル)      : %s\n", config.OutputNPYDirectory)
+	fmt.Printf("出力先のディレクトリ(PNGファイル)      : %s\n", config.OutputPNGDirectory)
+	fmt.Printf("差分演算の境界条件 : %s, 格子 : %s\n", config.FieldBoundary, config.FieldStaggering)
+	fmt.Printf("全エネルギーのずれの警告 : %g\n", config.EnergyDriftThreshold)
+	fmt.Printf("密度の単位 : %s, 波長の単位 : %s\n", config.DensityUnit, config.LaserWavelengthUnit)
+	fmt.Printf("位相空間の軸 : %s\n", config.PhaseAxis)
+	fmt.Printf("領域分割 : %s\n", config.Decomposition)
+	fmt.Printf("カットオフの粒子数 : %g, 粒子数を数えるエネルギー : %s\n", config.CutoffCount, config.AboveEnergies)
 	fmt.Println("")
 	fmt.Println("出力するデータ")
 	for _, v := range config.Field {
@@ -96,4 +348,19 @@ func ShowPlotConfig(config Art) {
 			fmt.Printf("%s : %s\n", v.Name, strings.Replace(v.Center, " ", ", ", -1))
 		}
 	}
+	for _, v := range config.Phase {
+		if v.Plot {
+			fmt.Printf("%s : %s\n", v.Name, strings.Replace(v.Center, " ", ", ", -1))
+		}
+	}
+	for _, species := range config.Species {
+		if !species.Plot {
+			continue
+		}
+		for _, v := range species.Particle {
+			if v.Plot {
+				fmt.Printf("%s(%s) %s : %s\n", species.Target, species.Label, v.Name, strings.Replace(v.Center, " ", ", ", -1))
+			}
+		}
+	}
 }
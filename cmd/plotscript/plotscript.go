@@ -0,0 +1,263 @@
+package plotscript
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// 出力ファイルの形式
+const (
+	Line  = "line"  // 1列目が横軸、2列目以降が値
+	Map   = "map"   // 空行区切りのブロックで並ぶ2次元データ(gnuplotのpm3d形式)
+	Table = "table" // 1行1ステップの表
+)
+
+// 出力ファイルの列
+type Column struct {
+	Name string
+	Unit string
+}
+
+// 出力の種類。Patternは出力ディレクトリからの相対パスで、%04dにステップ番号が入ります。
+// ValuesはLine, Mapで軸の列に続く値の列の数で、0のときは1列だけを描きます(残りの列は補助の値とみなします)。
+type Kind struct {
+	Name    string
+	Pattern string
+	Format  string
+	Slice   string
+	Columns []Column
+	Values  int
+	LogX    bool
+	LogY    bool
+}
+
+// 描く値の列の番号(0始まり)を返します。Lineは2列目、Mapは3列目からValues列です。
+func (kind Kind) valueColumns() []int {
+	first := 1
+	if kind.Format == Map {
+		first = 2
+	}
+	n := kind.Values
+	if n < 1 {
+		n = 1
+	}
+	columns := []int{}
+	for i := first; i < first+n && i < len(kind.Columns); i++ {
+		columns = append(columns, i)
+	}
+	return columns
+}
+
+type entry struct {
+	kind  Kind
+	steps map[int]bool
+}
+
+var (
+	mutex    sync.Mutex
+	registry = map[string]*entry{}
+)
+
+// 書き出したファイルを出力の種類とステップ番号とともに登録します。
+func Register(kind Kind, fileID int) {
+	mutex.Lock()
+	defer mutex.Unlock()
+	e, found := registry[kind.Name]
+	if !found {
+		e = &entry{kind: kind, steps: map[int]bool{}}
+		registry[kind.Name] = e
+	}
+	e.steps[fileID] = true
+}
+
+func (e *entry) sortedSteps() []int {
+	steps := []int{}
+	for s := range e.steps {
+		steps = append(steps, s)
+	}
+	sort.Ints(steps)
+	return steps
+}
+
+func columnLabel(c Column) string {
+	if c.Unit == "" {
+		return c.Name
+	}
+	return fmt.Sprintf("%s (%s)", c.Name, c.Unit)
+}
+
+func header(comment string, kind Kind, steps []int) string {
+	var b strings.Builder
+	b.WriteString(fmt.Sprintf("%s goplotが生成した %s のプロット用スクリプト\n", comment, kind.Name))
+	b.WriteString(fmt.Sprintf("%s ファイル : %s\n", comment, kind.Pattern))
+	if kind.Slice != "" {
+		b.WriteString(fmt.Sprintf("%s 切り出し : %s\n", comment, kind.Slice))
+	}
+	for i, c := range kind.Columns {
+		b.WriteString(fmt.Sprintf("%s %d列目 : %s\n", comment, i+1, columnLabel(c)))
+	}
+	b.WriteString(fmt.Sprintf("%s ステップ数 : %d\n", comment, len(steps)))
+	return b.String()
+}
+
+func writeGnuplot(fname string, kind Kind, steps []int) error {
+	fout, err := os.Create(fname)
+	if err != nil {
+		return err
+	}
+	defer fout.Close()
+	writer := bufio.NewWriter(fout)
+	writer.WriteString(header("#", kind, steps))
+	writer.WriteString("set terminal pngcairo size 800,600\n")
+	stepStrings := []string{}
+	for _, s := range steps {
+		stepStrings = append(stepStrings, fmt.Sprint(s))
+	}
+	writer.WriteString(fmt.Sprintf("steps = \"%s\"\n", strings.Join(stepStrings, " ")))
+	if kind.LogX {
+		writer.WriteString("set logscale x\n")
+	}
+	if kind.LogY {
+		writer.WriteString("set logscale y\n")
+	}
+	png := strings.Replace(kind.Pattern, ".txt", ".png", 1)
+	switch kind.Format {
+	case Map:
+		writer.WriteString(fmt.Sprintf("set xlabel \"%s\"\nset ylabel \"%s\"\nset cblabel \"%s\"\n", columnLabel(kind.Columns[0]), columnLabel(kind.Columns[1]), columnLabel(kind.Columns[2])))
+		writer.WriteString("set pm3d map\nset size ratio -1\n")
+		writer.WriteString("do for [s in steps] {\n")
+		values := kind.valueColumns()
+		for _, c := range values {
+			// 値の列が複数あれば列ごとに別の画像にする
+			output, title := png, kind.Name
+			if len(values) > 1 {
+				output = strings.Replace(png, ".png", fmt.Sprintf("_col%d.png", c+1), 1)
+				title = kind.Name + " " + columnLabel(kind.Columns[c])
+				writer.WriteString(fmt.Sprintf("  set cblabel \"%s\"\n", columnLabel(kind.Columns[c])))
+			}
+			writer.WriteString(fmt.Sprintf("  set output sprintf(\"%s\", s+0)\n", output))
+			writer.WriteString(fmt.Sprintf("  set title sprintf(\"%s step %%d\", s+0) noenhanced\n", title))
+			writer.WriteString(fmt.Sprintf("  splot sprintf(\"%s\", s+0) using 1:2:%d with pm3d notitle\n", kind.Pattern, c+1))
+		}
+		writer.WriteString("}\n")
+	case Table:
+		writer.WriteString(fmt.Sprintf("set xlabel \"%s\"\n", columnLabel(kind.Columns[0])))
+		writer.WriteString(fmt.Sprintf("set output \"%s\"\n", strings.Replace(png, "%04d", "", 1)))
+		plots := []string{}
+		for i := 1; i < len(kind.Columns); i++ {
+			plots = append(plots, fmt.Sprintf("\"%s\" using 1:%d with lines title \"%s\" noenhanced", kind.Pattern, i+1, columnLabel(kind.Columns[i])))
+		}
+		writer.WriteString("plot " + strings.Join(plots, ", \\\n     ") + "\n")
+	default:
+		values := kind.valueColumns()
+		ylabel := columnLabel(kind.Columns[1])
+		if len(values) > 1 {
+			ylabel = columnLabel(Column{Name: "value", Unit: kind.Columns[1].Unit})
+		}
+		writer.WriteString(fmt.Sprintf("set xlabel \"%s\"\nset ylabel \"%s\"\n", columnLabel(kind.Columns[0]), ylabel))
+		writer.WriteString("do for [s in steps] {\n")
+		writer.WriteString(fmt.Sprintf("  set output sprintf(\"%s\", s+0)\n", png))
+		writer.WriteString(fmt.Sprintf("  set title sprintf(\"%s step %%d\", s+0) noenhanced\n", kind.Name))
+		if len(values) == 1 {
+			writer.WriteString(fmt.Sprintf("  plot sprintf(\"%s\", s+0) using 1:2 with lines notitle\n", kind.Pattern))
+		} else {
+			plots := []string{}
+			for _, c := range values {
+				plots = append(plots, fmt.Sprintf("sprintf(\"%s\", s+0) using 1:%d with lines title \"%s\" noenhanced", kind.Pattern, c+1, columnLabel(kind.Columns[c])))
+			}
+			writer.WriteString("  plot " + strings.Join(plots, ", \\\n       ") + "\n")
+		}
+		writer.WriteString("}\n")
+	}
+	return writer.Flush()
+}
+
+func writeMatplotlib(fname string, kind Kind, steps []int) error {
+	fout, err := os.Create(fname)
+	if err != nil {
+		return err
+	}
+	defer fout.Close()
+	writer := bufio.NewWriter(fout)
+	writer.WriteString(header("#", kind, steps))
+	writer.WriteString("import os\nimport numpy as np\nimport matplotlib\nmatplotlib.use(\"Agg\")\nimport matplotlib.pyplot as plt\n\n")
+	writer.WriteString("os.chdir(os.path.dirname(os.path.abspath(__file__)))\n")
+	stepStrings := []string{}
+	for _, s := range steps {
+		stepStrings = append(stepStrings, fmt.Sprint(s))
+	}
+	writer.WriteString(fmt.Sprintf("steps = [%s]\n", strings.Join(stepStrings, ", ")))
+	labels := []string{}
+	for _, c := range kind.Columns {
+		labels = append(labels, fmt.Sprintf("%q", columnLabel(c)))
+	}
+	writer.WriteString(fmt.Sprintf("labels = [%s]\n", strings.Join(labels, ", ")))
+	values := []string{}
+	for _, c := range kind.valueColumns() {
+		values = append(values, fmt.Sprint(c))
+	}
+	writer.WriteString(fmt.Sprintf("values = [%s]\n\n", strings.Join(values, ", ")))
+	png := strings.Replace(kind.Pattern, ".txt", ".png", 1)
+	switch kind.Format {
+	case Map:
+		writer.WriteString("for s in steps:\n")
+		writer.WriteString(fmt.Sprintf("    d = np.loadtxt(%q %% s)\n", kind.Pattern))
+		writer.WriteString("    n = len(np.unique(d[:, 0]))\n")
+		writer.WriteString("    a, b = (d[:, i].reshape(n, -1) for i in range(2))\n")
+		writer.WriteString("    # 値の列が複数あれば列ごとに別の画像にする\n")
+		writer.WriteString("    for c in values:\n")
+		writer.WriteString("        fig, ax = plt.subplots()\n")
+		writer.WriteString("        m = ax.pcolormesh(a, b, d[:, c].reshape(n, -1), shading=\"auto\")\n")
+		writer.WriteString("        fig.colorbar(m, ax=ax, label=labels[c])\n")
+		writer.WriteString("        ax.set_xlabel(labels[0])\n        ax.set_ylabel(labels[1])\n        ax.set_aspect(\"equal\")\n")
+		writer.WriteString(fmt.Sprintf("        ax.set_title(\"%s step %%d\" %% s)\n", kind.Name))
+		writer.WriteString(fmt.Sprintf("        fig.savefig(%q %% s if len(values) == 1 else %q %% (s, c + 1))\n", png, strings.Replace(png, ".png", "_col%d.png", 1)))
+		writer.WriteString("        plt.close(fig)\n")
+	case Table:
+		writer.WriteString(fmt.Sprintf("d = np.atleast_2d(np.loadtxt(%q))\n", kind.Pattern))
+		writer.WriteString("fig, ax = plt.subplots()\n")
+		writer.WriteString("for i in range(1, d.shape[1]):\n")
+		writer.WriteString("    ax.plot(d[:, 0], d[:, i], label=labels[i])\n")
+		writer.WriteString("ax.set_xlabel(labels[0])\nax.legend()\n")
+		writer.WriteString(fmt.Sprintf("fig.savefig(%q)\n", strings.Replace(png, "%04d", "", 1)))
+	default:
+		writer.WriteString("for s in steps:\n")
+		writer.WriteString(fmt.Sprintf("    d = np.atleast_2d(np.loadtxt(%q %% s))\n", kind.Pattern))
+		writer.WriteString("    fig, ax = plt.subplots()\n")
+		writer.WriteString("    for c in values:\n")
+		writer.WriteString("        ax.plot(d[:, 0], d[:, c], label=labels[c])\n")
+		writer.WriteString("    if len(values) > 1:\n        ax.legend()\n")
+		if kind.LogX {
+			writer.WriteString("    ax.set_xscale(\"log\")\n")
+		}
+		if kind.LogY {
+			writer.WriteString("    ax.set_yscale(\"log\")\n")
+		}
+		writer.WriteString("    ax.set_xlabel(labels[0])\n")
+		writer.WriteString("    ax.set_ylabel(labels[1] if len(values) == 1 else \"value\")\n")
+		writer.WriteString(fmt.Sprintf("    ax.set_title(\"%s step %%d\" %% s)\n", kind.Name))
+		writer.WriteString(fmt.Sprintf("    fig.savefig(%q %% s)\n", png))
+		writer.WriteString("    plt.close(fig)\n")
+	}
+	return writer.Flush()
+}
+
+// 登録された出力の種類ごとに、gnuplotとmatplotlibのスクリプトを書き出します。
+func WriteScripts(dir string) error {
+	mutex.Lock()
+	defer mutex.Unlock()
+	for name, e := range registry {
+		steps := e.sortedSteps()
+		if err := writeGnuplot(fmt.Sprintf("%s/%s.gp", dir, name), e.kind, steps); err != nil {
+			return err
+		}
+		if err := writeMatplotlib(fmt.Sprintf("%s/%s.py", dir, name), e.kind, steps); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,110 @@
+package probe
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/Penpen7/goplot/cmd/field"
+	"github.com/Penpen7/goplot/cmd/fieldop"
+	"github.com/Penpen7/goplot/cmd/physconst"
+	"github.com/Penpen7/goplot/cmd/plotconfig"
+	"github.com/Penpen7/goplot/cmd/plotscript"
+	"github.com/Penpen7/goplot/cmd/simulationconfig"
+	"github.com/Penpen7/goplot/cmd/utility"
+)
+
+// 1ステップ分の記録
+type sample struct {
+	time   float32
+	fileID int
+	value  float32
+}
+
+// 1つのプローブの1つの物理量の時系列
+type series struct {
+	probe    plotconfig.Probe
+	quantity string
+	unit     string
+	point    [3]float64
+	samples  []sample
+}
+
+var (
+	mutex    sync.Mutex
+	recorded = map[string]*series{}
+	order    []string
+)
+
+// plot.jsonのProbeに指定された点の値を、全ての有効な物理量について記録します。
+func Record(fields field.FieldSet, particles field.ParticleMeshSet, config simulationconfig.SimulationConfig, plotConfig plotconfig.Art, simulationTime float32, fileID int) {
+	mutex.Lock()
+	defer mutex.Unlock()
+	size := [3]int{int(config.OutputMeshNumber[0]), int(config.OutputMeshNumber[1]), int(config.OutputMeshNumber[2])}
+	grid := fieldop.NewGrid(config, plotConfig.FieldBoundary, plotConfig.FieldStaggering)
+	for _, p := range plotConfig.Probe {
+		if !p.Plot {
+			continue
+		}
+		point, err := field.ParsePoint(p.Position, size)
+		if err != nil {
+			fmt.Println("Warning:invalid probe position:", p.Name, err)
+			continue
+		}
+		for _, quantity := range strings.Fields(p.Quantities) {
+			g, found := particles.Lookup(quantity, config, plotConfig)
+			if !found {
+				g, found = particles.Derived(quantity, config)
+			}
+			if !found {
+				g, found = fields.Lookup(quantity, grid)
+			}
+			if !found {
+				fmt.Println("Warning:unknown probe quantity:", quantity)
+				continue
+			}
+			// 密度は場の出力と同じくplot.jsonのDensityUnitに換算する
+			value, unit := field.InDensityUnit(utility.Trilinear(g, point[0], point[1], point[2]), quantity, plotConfig)
+			key := p.Name + "_" + quantity
+			if _, exists := recorded[key]; !exists {
+				recorded[key] = &series{probe: p, quantity: quantity, unit: unit, point: point}
+				order = append(order, key)
+			}
+			s := recorded[key]
+			s.samples = append(s.samples, sample{simulationTime, fileID, value})
+		}
+	}
+}
+
+// 記録した時系列を、プローブと物理量の組ごとに1つのファイルへ書き出します。
+func Write(dir string) error {
+	mutex.Lock()
+	defer mutex.Unlock()
+	for _, key := range order {
+		s := recorded[key]
+		fname := fmt.Sprintf("Probe_%s.txt", key)
+		fout, err := os.Create(fmt.Sprintf("%s/%s", dir, fname))
+		if err != nil {
+			return err
+		}
+		writer := bufio.NewWriter(fout)
+		writer.WriteString(fmt.Sprintf("# probe %s : %s at (%g, %g, %g) grid = (%g, %g, %g) um\n", s.probe.Name, s.quantity,
+			s.point[0], s.point[1], s.point[2],
+			s.point[0]*float64(physconst.OutputMeshSpacing[0]), s.point[1]*float64(physconst.OutputMeshSpacing[1]), s.point[2]*float64(physconst.OutputMeshSpacing[2])))
+		writer.WriteString(fmt.Sprintf("# time value(%s) step\n", s.unit))
+		for _, v := range s.samples {
+			writer.WriteString(fmt.Sprintln(v.time, v.value, v.fileID))
+		}
+		writer.Flush()
+		fout.Close()
+		plotscript.Register(plotscript.Kind{
+			Name:    fmt.Sprintf("Probe_%s", key),
+			Pattern: fname,
+			Format:  plotscript.Table,
+			Columns: []plotscript.Column{{Name: "time", Unit: "normalized"}, {Name: s.quantity, Unit: s.unit}},
+		}, 0)
+	}
+	return nil
+}
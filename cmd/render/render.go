@@ -0,0 +1,135 @@
+package render
+
+import (
+	"image"
+	"image/color"
+	"image/png"
+	"math"
+	"os"
+	"sync"
+)
+
+// viridisを近似したカラーマップの制御点
+var colormap = [...][3]float64{
+	{68, 1, 84}, {72, 40, 120}, {62, 74, 137}, {49, 104, 142}, {38, 130, 142},
+	{31, 158, 137}, {53, 183, 121}, {109, 205, 89}, {180, 222, 44}, {253, 231, 37},
+}
+
+// 描画の設定
+type Options struct {
+	// trueのとき色を対数スケールにします。0以下の値は最小値の色になります。
+	Log bool
+	// 対数スケールで表示する桁数。0のときは8桁です。
+	Decades float64
+	// 1データ点あたりのピクセル数。0のときは1です。
+	Scale int
+	// 白線で等値線を描く値
+	Contours []float64
+}
+
+// 0から1の値を色に変換します。
+func colorAt(t float64) color.RGBA {
+	t = math.Max(0, math.Min(1, t))
+	p := t * float64(len(colormap)-1)
+	i := int(math.Floor(p))
+	if i >= len(colormap)-1 {
+		i = len(colormap) - 2
+	}
+	f := p - float64(i)
+	var c [3]uint8
+	for k := 0; k < 3; k++ {
+		c[k] = uint8(colormap[i][k] + f*(colormap[i+1][k]-colormap[i][k]))
+	}
+	return color.RGBA{c[0], c[1], c[2], 255}
+}
+
+// 表示範囲を求めます。対数スケールの場合はlog10の値です。
+func valueRange(data [][]float32, options Options) (float64, float64) {
+	min, max := math.Inf(1), math.Inf(-1)
+	for _, row := range data {
+		for _, v := range row {
+			value := float64(v)
+			if math.IsNaN(value) || math.IsInf(value, 0) || (options.Log && value <= 0) {
+				continue
+			}
+			min, max = math.Min(min, value), math.Max(max, value)
+		}
+	}
+	if math.IsInf(min, 0) {
+		return 0, 1
+	}
+	if options.Log {
+		decades := options.Decades
+		if decades == 0 {
+			decades = 8
+		}
+		max = math.Log10(max)
+		min = math.Max(math.Log10(min), max-decades)
+	}
+	if min == max {
+		max = min + 1
+	}
+	return min, max
+}
+
+// 2次元配列をカラーマップでPNGに書き出します。data[i][j]のiが横軸、jが縦軸(上向き)です。
+func WriteHeatmap(data [][]float32, options Options, fname string, wg *sync.WaitGroup) {
+	scale := options.Scale
+	if scale == 0 {
+		scale = 1
+	}
+	nx, ny := len(data), len(data[0])
+	img := image.NewRGBA(image.Rect(0, 0, nx*scale, ny*scale))
+	min, max := valueRange(data, options)
+	for i := 0; i < nx; i++ {
+		for j := 0; j < ny; j++ {
+			value := float64(data[i][j])
+			if options.Log {
+				if value > 0 {
+					value = math.Log10(value)
+				} else {
+					value = min
+				}
+			}
+			c := colorAt((value - min) / (max - min))
+			for dx := 0; dx < scale; dx++ {
+				for dy := 0; dy < scale; dy++ {
+					img.SetRGBA(i*scale+dx, (ny-1-j)*scale+dy, c)
+				}
+			}
+		}
+	}
+	for _, level := range options.Contours {
+		drawContour(img, data, level, scale)
+	}
+	fout, err := os.Create(fname)
+	defer fout.Close()
+	if err != nil {
+		panic(err)
+	}
+	if err := png.Encode(fout, img); err != nil {
+		panic(err)
+	}
+	fout.Close()
+	wg.Done()
+}
+
+// 隣り合う点の間で値がlevelをまたぐ点を白く塗り、等値線を描きます。
+func drawContour(img *image.RGBA, data [][]float32, level float64, scale int) {
+	white := color.RGBA{255, 255, 255, 255}
+	crosses := func(a, b float32) bool {
+		return (float64(a) < level) != (float64(b) < level)
+	}
+	nx, ny := len(data), len(data[0])
+	for i := 0; i < nx; i++ {
+		for j := 0; j < ny; j++ {
+			if (i+1 < nx && crosses(data[i][j], data[i+1][j])) || (j+1 < ny && crosses(data[i][j], data[i][j+1])) {
+				for dx := 0; dx < scale; dx++ {
+					for dy := 0; dy < scale; dy++ {
+						img.SetRGBA(i*scale+dx, (ny-1-j)*scale+dy, white)
+					}
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,55 @@
+package runtable
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/Penpen7/goplot/cmd/plotscript"
+)
+
+var (
+	mutex sync.Mutex
+	// 表ごとに最後に書いたステップ。実行中にまだ書いていない表はありません。
+	lastFileID = map[string]int{}
+)
+
+// 1ステップ1行の表name.txtにrowを追記します。
+// 実行中に最初に書くときにファイルを作り直し、columnsから列の説明を書きます。
+// 同じステップ(fileID)の行がすでにあれば何もしません。
+func Append(dir string, name string, fileID int, columns []plotscript.Column, row []float64) {
+	mutex.Lock()
+	defer mutex.Unlock()
+	last, exists := lastFileID[name]
+	if exists && last == fileID {
+		return
+	}
+	flag := os.O_WRONLY | os.O_CREATE | os.O_APPEND
+	if !exists {
+		flag |= os.O_TRUNC
+	}
+	fout, err := os.OpenFile(fmt.Sprintf("%s/%s.txt", dir, name), flag, 0666)
+	if err != nil {
+		panic(err)
+	}
+	defer fout.Close()
+	if !exists {
+		labels := []string{}
+		for _, c := range columns {
+			if c.Unit == "" {
+				labels = append(labels, c.Name)
+			} else {
+				labels = append(labels, fmt.Sprintf("%s(%s)", c.Name, c.Unit))
+			}
+		}
+		fout.WriteString("# " + strings.Join(labels, " ") + "\n")
+	}
+	values := make([]string, len(row))
+	for i, v := range row {
+		values[i] = fmt.Sprint(v)
+	}
+	fout.WriteString(strings.Join(values, " ") + "\n")
+	lastFileID[name] = fileID
+	plotscript.Register(plotscript.Kind{Name: name, Pattern: name + ".txt", Format: plotscript.Table, Columns: columns}, fileID)
+}
@@ -196,7 +196,7 @@ func LoadSetting(fname string) (SimulationConfig, error) {
 		binary.Read(fortbin.ReadNextChunk(file), binary.LittleEndian, &config.Particle[ionID].ParticleOutGoing[1])
 		binary.Read(fortbin.ReadNextChunk(file), binary.LittleEndian, &config.Particle[ionID].ParticleOutGoing[2])
 		if config.UsedIonize {
-			binary.Read(fortbin.ReadNextChunk(file), binary.LittleEndian, &config.Particle[ionID].Atom)
+			config.Particle[ionID].Atom = strings.TrimSpace(fmt.Sprintf("%s", fortbin.ReadNextChunk(file).Bytes()))
 			binary.Read(fortbin.ReadNextChunk(file), binary.LittleEndian, &config.Particle[ionID].ParticleInitialChargeForIonize)
 
 		}
@@ -0,0 +1,207 @@
+package svgplot
+
+import (
+	"bufio"
+	"fmt"
+	"math"
+	"os"
+	"strings"
+	"sync"
+)
+
+const (
+	width        = 720
+	height       = 480
+	marginLeft   = 90
+	marginRight  = 170
+	marginTop    = 40
+	marginBottom = 60
+)
+
+// 系列ごとの線の色
+var palette = [...]string{"#1f77b4", "#d62728", "#2ca02c", "#ff7f0e", "#9467bd", "#8c564b", "#e377c2", "#7f7f7f", "#bcbd22", "#17becf"}
+
+// 折れ線グラフの1系列
+type Series struct {
+	Label string
+	X     []float64
+	Y     []float64
+}
+
+// 軸の設定。Logがtrueのとき対数軸になります。
+type Axis struct {
+	Label string
+	Unit  string
+	Log   bool
+}
+
+// 1枚のグラフ。複数の系列を重ねて描画します。
+type Chart struct {
+	Title  string
+	XAxis  Axis
+	YAxis  Axis
+	Series []Series
+}
+
+// 対数軸で描けない値、もしくは非有限値であればfalseを返します。
+func valid(v float64, log bool) bool {
+	if math.IsNaN(v) || math.IsInf(v, 0) {
+		return false
+	}
+	return !log || v > 0
+}
+
+func transform(v float64, log bool) float64 {
+	if log {
+		return math.Log10(v)
+	}
+	return v
+}
+
+// 全系列から描画範囲を求めます。範囲は変換後(対数軸ならlog10)の値です。
+func dataRange(chart Chart) (xmin, xmax, ymin, ymax float64, ok bool) {
+	xmin, ymin = math.Inf(1), math.Inf(1)
+	xmax, ymax = math.Inf(-1), math.Inf(-1)
+	for _, s := range chart.Series {
+		for i := range s.X {
+			if i >= len(s.Y) || !valid(s.X[i], chart.XAxis.Log) || !valid(s.Y[i], chart.YAxis.Log) {
+				continue
+			}
+			x := transform(s.X[i], chart.XAxis.Log)
+			y := transform(s.Y[i], chart.YAxis.Log)
+			xmin, xmax = math.Min(xmin, x), math.Max(xmax, x)
+			ymin, ymax = math.Min(ymin, y), math.Max(ymax, y)
+			ok = true
+		}
+	}
+	if !ok {
+		return 0, 1, 0, 1, false
+	}
+	if xmin == xmax {
+		xmin, xmax = xmin-0.5, xmax+0.5
+	}
+	if ymin == ymax {
+		ymin, ymax = ymin-0.5, ymax+0.5
+	}
+	return xmin, xmax, ymin, ymax, true
+}
+
+// 線形軸の目盛りを1, 2, 5 x 10^nの刻みで求めます。
+func linearTicks(min, max float64) []float64 {
+	raw := (max - min) / 6
+	magnitude := math.Pow(10, math.Floor(math.Log10(raw)))
+	step := magnitude
+	for _, m := range []float64{1, 2, 5, 10} {
+		step = m * magnitude
+		if step >= raw {
+			break
+		}
+	}
+	ticks := []float64{}
+	for t := math.Ceil(min/step) * step; t <= max+step*1e-9; t += step {
+		if math.Abs(t) < step*1e-9 {
+			t = 0
+		}
+		ticks = append(ticks, t)
+	}
+	return ticks
+}
+
+// 対数軸の目盛りを10のべき乗ごとに求めます。min, maxはlog10の値です。
+func logTicks(min, max float64) []float64 {
+	step := math.Max(1, math.Ceil((max-min)/8))
+	ticks := []float64{}
+	for t := math.Ceil(min); t <= max; t += step {
+		ticks = append(ticks, t)
+	}
+	return ticks
+}
+
+func tickLabel(v float64, log bool) string {
+	if log {
+		return fmt.Sprintf("1e%d", int(math.Round(v)))
+	}
+	return fmt.Sprintf("%.4g", v)
+}
+
+func axisTitle(axis Axis) string {
+	if axis.Unit == "" {
+		return axis.Label
+	}
+	return fmt.Sprintf("%s (%s)", axis.Label, axis.Unit)
+}
+
+func escape(s string) string {
+	return strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;", "\"", "&quot;").Replace(s)
+}
+
+// 折れ線グラフをSVGで書き出します。
+func WriteLinePlot(chart Chart, fname string, wg *sync.WaitGroup) {
+	fout, err := os.Create(fname)
+	defer fout.Close()
+	if err != nil {
+		panic(err)
+	}
+	writer := bufio.NewWriter(fout)
+
+	plotWidth := float64(width - marginLeft - marginRight)
+	plotHeight := float64(height - marginTop - marginBottom)
+	xmin, xmax, ymin, ymax, _ := dataRange(chart)
+	px := func(x float64) float64 { return marginLeft + (x-xmin)/(xmax-xmin)*plotWidth }
+	py := func(y float64) float64 { return marginTop + (ymax-y)/(ymax-ymin)*plotHeight }
+
+	writer.WriteString(fmt.Sprintf("<svg xmlns=\"http://www.w3.org/2000/svg\" width=\"%d\" height=\"%d\" font-family=\"sans-serif\" font-size=\"12\">\n", width, height))
+	writer.WriteString("<rect width=\"100%\" height=\"100%\" fill=\"white\"/>\n")
+	writer.WriteString(fmt.Sprintf("<text x=\"%g\" y=\"%d\" text-anchor=\"middle\" font-size=\"14\">%s</text>\n", marginLeft+plotWidth/2, marginTop/2+5, escape(chart.Title)))
+
+	// 目盛り
+	xticks, yticks := linearTicks(xmin, xmax), linearTicks(ymin, ymax)
+	if chart.XAxis.Log {
+		xticks = logTicks(xmin, xmax)
+	}
+	if chart.YAxis.Log {
+		yticks = logTicks(ymin, ymax)
+	}
+	for _, t := range xticks {
+		x := px(t)
+		writer.WriteString(fmt.Sprintf("<line x1=\"%.2f\" y1=\"%d\" x2=\"%.2f\" y2=\"%.2f\" stroke=\"#e0e0e0\"/>\n", x, marginTop, x, marginTop+plotHeight))
+		writer.WriteString(fmt.Sprintf("<text x=\"%.2f\" y=\"%.2f\" text-anchor=\"middle\">%s</text>\n", x, marginTop+plotHeight+18, tickLabel(t, chart.XAxis.Log)))
+	}
+	for _, t := range yticks {
+		y := py(t)
+		writer.WriteString(fmt.Sprintf("<line x1=\"%d\" y1=\"%.2f\" x2=\"%.2f\" y2=\"%.2f\" stroke=\"#e0e0e0\"/>\n", marginLeft, y, marginLeft+plotWidth, y))
+		writer.WriteString(fmt.Sprintf("<text x=\"%d\" y=\"%.2f\" text-anchor=\"end\">%s</text>\n", marginLeft-6, y+4, tickLabel(t, chart.YAxis.Log)))
+	}
+	writer.WriteString(fmt.Sprintf("<rect x=\"%d\" y=\"%d\" width=\"%g\" height=\"%g\" fill=\"none\" stroke=\"black\"/>\n", marginLeft, marginTop, plotWidth, plotHeight))
+	writer.WriteString(fmt.Sprintf("<text x=\"%g\" y=\"%d\" text-anchor=\"middle\">%s</text>\n", marginLeft+plotWidth/2, height-15, escape(axisTitle(chart.XAxis))))
+	writer.WriteString(fmt.Sprintf("<text transform=\"translate(20 %g) rotate(-90)\" text-anchor=\"middle\">%s</text>\n", marginTop+plotHeight/2, escape(axisTitle(chart.YAxis))))
+
+	// 系列。描けない点で線を区切ります。
+	for si, s := range chart.Series {
+		color := palette[si%len(palette)]
+		points := []string{}
+		flush := func() {
+			if len(points) > 0 {
+				writer.WriteString(fmt.Sprintf("<polyline fill=\"none\" stroke=\"%s\" stroke-width=\"1.5\" points=\"%s\"/>\n", color, strings.Join(points, " ")))
+			}
+			points = points[:0]
+		}
+		for i := range s.X {
+			if i >= len(s.Y) || !valid(s.X[i], chart.XAxis.Log) || !valid(s.Y[i], chart.YAxis.Log) {
+				flush()
+				continue
+			}
+			points = append(points, fmt.Sprintf("%.2f,%.2f", px(transform(s.X[i], chart.XAxis.Log)), py(transform(s.Y[i], chart.YAxis.Log))))
+		}
+		flush()
+
+		ly := float64(marginTop + 10 + 18*si)
+		lx := marginLeft + plotWidth + 12
+		writer.WriteString(fmt.Sprintf("<line x1=\"%g\" y1=\"%g\" x2=\"%g\" y2=\"%g\" stroke=\"%s\" stroke-width=\"2\"/>\n", lx, ly, lx+24, ly, color))
+		writer.WriteString(fmt.Sprintf("<text x=\"%g\" y=\"%g\">%s</text>\n", lx+30, ly+4, escape(s.Label)))
+	}
+	writer.WriteString("</svg>\n")
+	writer.Flush()
+	fout.Close()
+	wg.Done()
+}
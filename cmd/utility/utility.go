@@ -1,6 +1,7 @@
 package utility
 
 import (
+	"math"
 	"os"
 )
 
@@ -30,26 +31,6 @@ func Slice1Dto3D(slice1D []float32, xsize int32, ysize int32, zsize int32, norma
 	return slice3D
 }
 
-// y-px, y-py, y-pzで出力される1次元配列を2次元配列に整形します。
-func Transpy(yp []float32, Ny_d int, parallelNumber int, momentumMeshNumber int) [][]float32 {
-	var res [][]float32 = make([][]float32, Ny_d)
-	for y := 0; y < Ny_d; y++ {
-		res[y] = make([]float32, len(yp)/int(Ny_d))
-	}
-
-	Ny_d_pe := Ny_d / parallelNumber
-
-	for ypindex, v := range yp {
-		mype := ypindex / (Ny_d_pe * momentumMeshNumber)
-		ipe := ypindex - mype*Ny_d_pe*momentumMeshNumber
-		kp := (ipe) / Ny_d_pe
-		ky_pe := (ipe) % (Ny_d_pe)
-		kynew := Ny_d_pe*mype + ky_pe
-		res[kynew][kp] = v
-	}
-	return res
-}
-
 // 1次元配列を2次元配列に変換します。
 func Slice1Dto2D(g []float32, xsize int32, ysize int32) [][]float32 {
 	var g2D [][]float32
@@ -78,3 +59,35 @@ func MakeDirectoryIgnoringExistance(dname string) error {
 	}
 	return nil
 }
+
+// 3次元配列の値を格子上の座標(x, y, z)で三線形補間します。範囲外の座標は端の値になります。
+func Trilinear(g [][][]float32, x float64, y float64, z float64) float32 {
+	size := [3]int{len(g), len(g[0]), len(g[0][0])}
+	position := [3]float64{x, y, z}
+	var index [3][2]int
+	var weight [3]float64
+	for axis := 0; axis < 3; axis++ {
+		p := math.Max(0, math.Min(position[axis], float64(size[axis]-1)))
+		i := int(math.Floor(p))
+		if i >= size[axis]-1 {
+			i = size[axis] - 1
+		}
+		index[axis] = [2]int{i, i + 1}
+		if i+1 >= size[axis] {
+			index[axis][1] = i
+		}
+		weight[axis] = p - float64(i)
+	}
+	value := 0.0
+	for ix := 0; ix < 2; ix++ {
+		for iy := 0; iy < 2; iy++ {
+			for iz := 0; iz < 2; iz++ {
+				w := (1 - weight[0] + float64(ix)*(2*weight[0]-1)) *
+					(1 - weight[1] + float64(iy)*(2*weight[1]-1)) *
+					(1 - weight[2] + float64(iz)*(2*weight[2]-1))
+				value += w * float64(g[index[0][ix]][index[1][iy]][index[2][iz]])
+			}
+		}
+	}
+	return float32(value)
+}
@@ -8,12 +8,15 @@ import (
 	"sync"
 	"time"
 
+	"github.com/Penpen7/goplot/cmd/decomposition"
 	"github.com/Penpen7/goplot/cmd/energydistribution"
 	"github.com/Penpen7/goplot/cmd/field"
 	"github.com/Penpen7/goplot/cmd/fortbin"
 	"github.com/Penpen7/goplot/cmd/phase"
 	"github.com/Penpen7/goplot/cmd/physconst"
 	"github.com/Penpen7/goplot/cmd/plotconfig"
+	"github.com/Penpen7/goplot/cmd/plotscript"
+	"github.com/Penpen7/goplot/cmd/probe"
 	"github.com/Penpen7/goplot/cmd/simulationconfig"
 	"github.com/Penpen7/goplot/cmd/utility"
 )
@@ -35,10 +38,22 @@ func loadSnap(file *os.File, config simulationconfig.SimulationConfig, fileID in
 	fmt.Println("")
 	fmt.Println("読み込んでいるシミュレーション上の規格化時間:", simulationTime)
 
-	field.LoadWriteFieldData(file, config, plotConfig, fileID, wg)
-	field.LoadWriteParticleMeshData(file, config, plotConfig, fileID, wg)
-	phase.LoadWritePhaseSpace(file, config, plotConfig, fileID, wg)
-	energydistribution.LoadWriteEnergyDistribution(file, config, plotConfig, fileID, wg)
+	fields := field.LoadWriteFieldData(file, config, plotConfig, fileID, wg)
+	particles := field.LoadWriteParticleMeshData(file, config, plotConfig, fileID, wg)
+	field.WriteGaussLaw(fields, particles, config, plotConfig, fileID, simulationTime, wg)
+	// 運動エネルギーは診断か加速の解析を出力するときだけ求める
+	var kinetic map[int32]float64
+	diagnostics, _ := plotconfig.FindSubart(plotConfig.Field, "Diagnostics")
+	acceleration, _ := plotconfig.FindSubart(plotConfig.EnergyDistribution, "Acceleration")
+	if diagnostics.Plot || acceleration.Plot {
+		kinetic = field.KineticEnergies(particles, config, plotConfig)
+	}
+	field.WriteDiagnostics(fields, particles, kinetic, config, plotConfig, fileID, simulationTime)
+	field.WriteFieldSpectrum(fields, config, plotConfig, fileID, wg)
+	field.RecordStreak(fields, particles, config, plotConfig, simulationTime)
+	probe.Record(fields, particles, config, plotConfig, simulationTime, fileID)
+	phase.LoadWritePhaseSpace(file, config, plotConfig, fileID, simulationTime, wg)
+	energydistribution.LoadWriteEnergyDistribution(file, config, plotConfig, fileID, simulationTime, kinetic, wg)
 	fmt.Printf("\r\033[K書き込み中...")
 	wg.Wait()
 	fmt.Printf("\r\033[K書き込み完了\n")
@@ -60,15 +75,12 @@ func main() {
 	plotconfig.ShowPlotConfig(plotConfig)
 
 	// outputDirectoryがあるか確認。なければディレクトリを作成する
-	if err := utility.MakeDirectoryIgnoringExistance(plotConfig.OutputASCIIDirectory); err != nil {
-		fmt.Printf("Error : %sが作れませんでした\n", plotConfig.OutputVTKDirectory)
-		fmt.Println(err)
-		os.Exit(-1)
-	}
-	if err := utility.MakeDirectoryIgnoringExistance(plotConfig.OutputVTKDirectory); err != nil {
-		fmt.Printf("Error : %sが作れませんでした\n", plotConfig.OutputVTKDirectory)
-		fmt.Println(err)
-		os.Exit(-1)
+	for _, directory := range []string{plotConfig.OutputASCIIDirectory, plotConfig.OutputVTKDirectory, plotConfig.OutputSVGDirectory, plotConfig.OutputNPYDirectory, plotConfig.OutputPNGDirectory} {
+		if err := utility.MakeDirectoryIgnoringExistance(directory); err != nil {
+			fmt.Printf("Error : %sが作れませんでした\n", directory)
+			fmt.Println(err)
+			os.Exit(-1)
+		}
 	}
 
 	// gfin.datを開き、シミュレーション設定を読み込む。
@@ -91,7 +103,21 @@ func main() {
 	// 設定を表示する
 	fmt.Println("")
 	fmt.Println("シミュレーションの設定")
-	physconst.CalculateNormalizeConstant(config)
+	if err := physconst.CalculateNormalizeConstant(config, plotConfig.LaserWavelengthUnit); err != nil {
+		fmt.Println("Warning:レーザーの波長の単位が正しくないため、波長を使う出力(k/k0, 臨界密度)は使いません")
+		fmt.Println(err)
+	} else if physconst.LaserWavelength > 0 && (physconst.LaserWavelength < 0.05 || physconst.LaserWavelength > 100) {
+		fmt.Printf("\x1b[35mwarning : レーザーの波長が%gµmになりました。plot.jsonのLaserWavelengthUnitを確認してください。\x1b[0m\n", physconst.LaserWavelength)
+	}
+	if err := field.CheckDensityUnit(plotConfig.DensityUnit); err != nil {
+		fmt.Println("Warning:密度の単位が正しくないため、密度を規格化単位(raw)で出力します")
+		fmt.Println(err)
+		plotConfig.DensityUnit = "raw"
+	}
+	if err := decomposition.Configure(config, plotConfig.Decomposition); err != nil {
+		fmt.Println("Warning:領域分割の指定が正しくないため、y方向の分割として読み込みます")
+		fmt.Println(err)
+	}
 
 	// snapを終端に達するまで読み込む。
 	for fileID := 0; ; fileID++ {
@@ -100,6 +126,30 @@ func main() {
 		}
 	}
 
+	// プローブの時系列を書き出す
+	if err := probe.Write(plotConfig.OutputASCIIDirectory); err != nil {
+		fmt.Println("Error : プローブの時系列が書き出せませんでした")
+		fmt.Println(err)
+	}
+
+	// 位相空間のVTKファイルの時系列を書き出す
+	if err := phase.WriteSeries(plotConfig.OutputVTKDirectory); err != nil {
+		fmt.Println("Error : 位相空間の時系列が書き出せませんでした")
+		fmt.Println(err)
+	}
+
+	// 時空間図を書き出す
+	if err := field.WriteStreaks(plotConfig); err != nil {
+		fmt.Println("Error : 時空間図が書き出せませんでした")
+		fmt.Println(err)
+	}
+
+	// 出力したテキストファイルごとにプロット用のスクリプトを書き出す
+	if err := plotscript.WriteScripts(plotConfig.OutputASCIIDirectory); err != nil {
+		fmt.Println("Error : プロット用のスクリプトが書き出せませんでした")
+		fmt.Println(err)
+	}
+
 	// 終了時間を記憶
 	end := time.Now()
 	fmt.Println("正常終了")